@@ -1,7 +1,62 @@
 import { JSONRPCRequest, JSONRPCResponse } from "~/entrypoints/shared/rpc"
+import { ClipboardMessage, handleClipboardMessage } from "~/entrypoints/shared/clipboard";
 import { Base64 } from 'js-base64';
 import { type Browser } from 'wxt/browser';
 
+// Functions that can be injected in a page through the scripting.executeScript method.
+// They are serialized by the browser, so they must not reference anything outside of their body.
+// Exceptions are reported by returning an { exception } object, as the browser does not forward them.
+const injectables = {
+  getPageMetrics: () => ({
+    scrollX: window.scrollX,
+    scrollY: window.scrollY,
+    innerWidth: window.innerWidth,
+    innerHeight: window.innerHeight,
+    scrollWidth: document.documentElement.scrollWidth,
+    scrollHeight: document.documentElement.scrollHeight,
+  }),
+  scrollToPosition: (x: number, y: number) => {
+    window.scrollTo({ left: x, top: y, behavior: "instant" });
+    return window.scrollY;
+  },
+  getElementRect: (selector: string) => {
+    const element = document.querySelector(selector);
+    if (!element) {
+      return null;
+    }
+
+    const rect = element.getBoundingClientRect();
+    return { left: rect.left + window.scrollX, top: rect.top + window.scrollY, width: rect.width, height: rect.height };
+  },
+  // the csp of the page can forbid eval in the main world, chromium browsers then evaluate the code through the debugger api
+  canEval: () => {
+    try {
+      (0, eval)("");
+      return true;
+    } catch {
+      return false;
+    }
+  },
+  // the csp of the extension forbids eval in the isolated world of chromium browsers
+  evaluate: async (code: string) => {
+    try {
+      const value = await (0, eval)(code);
+      // round-trip through JSON to drop values that can't be sent back (functions, DOM nodes...)
+      return { value: value === undefined ? null : JSON.parse(JSON.stringify(value) ?? "null") };
+    } catch (error) {
+      const { name, message, stack } = error instanceof Error ? error : new Error(String(error));
+      return { exception: { name, message, stack } };
+    }
+  },
+  extractContent: (selector: string | null, format: "html" | "text") => {
+    const elements = selector ? Array.from(document.querySelectorAll(selector)) : [document.documentElement];
+    return {
+      url: location.href,
+      contents: elements.map((element) => format === "text" ? (element as HTMLElement).innerText : element.outerHTML),
+    };
+  },
+}
+
 export default defineBackground(() => {
   let _nativePort: Browser.runtime.Port | null = null;
 
@@ -62,6 +117,183 @@ export default defineBackground(() => {
     return _nativePort;
   }
 
+  let offscreenDocument: Promise<void> | null = null;
+
+  // service workers have no DOM, so chrome accesses the clipboard through an offscreen document,
+  // while firefox background pages can access it directly
+  async function sendClipboardMessage(message: ClipboardMessage): Promise<{ data?: string }> {
+    if (!browser.offscreen) {
+      return handleClipboardMessage(message);
+    }
+
+    offscreenDocument ??= browser.offscreen.createDocument({
+      url: browser.runtime.getURL("/offscreen.html"),
+      reasons: [browser.offscreen.Reason.CLIPBOARD],
+      justification: "Read and write the clipboard from the terminal",
+    }).catch((error: Error) => {
+      // the document survives service worker restarts
+      if (!error.message.includes("single offscreen document")) {
+        offscreenDocument = null;
+        throw error;
+      }
+    });
+    await offscreenDocument;
+
+    const res = await browser.runtime.sendMessage<ClipboardMessage, { data?: string, error?: string }>(message);
+    if (res.error) {
+      throw new Error(res.error);
+    }
+
+    return res;
+  }
+
+  // forward browser events to the native host, which dispatches them to waiting commands
+  function sendNotification(method: string, params: unknown) {
+    _nativePort?.postMessage({
+      jsonrpc: "2.0",
+      method,
+      params,
+    });
+  }
+
+  browser.downloads.onChanged.addListener((delta) => {
+    sendNotification("downloads.onChanged", delta);
+  });
+
+  browser.notifications.onClicked.addListener((notificationId) => {
+    sendNotification("notifications.onClicked", { notificationId });
+  });
+
+  browser.notifications.onButtonClicked.addListener((notificationId, buttonIndex) => {
+    sendNotification("notifications.onButtonClicked", { notificationId, buttonIndex });
+  });
+
+  browser.notifications.onClosed.addListener((notificationId, byUser) => {
+    sendNotification("notifications.onClosed", { notificationId, byUser });
+  });
+
+  // tabs the debugger was attached to through the debugger.attach method
+  const attachedTabs = new Set<number>();
+
+  browser.debugger?.onEvent.addListener((source, method, params) => {
+    sendNotification("debugger.onEvent", { source, method, params });
+  });
+
+  browser.debugger?.onDetach.addListener((source, reason) => {
+    if (source.tabId) {
+      attachedTabs.delete(source.tabId);
+    }
+
+    sendNotification("debugger.onDetach", { source, reason });
+  });
+
+  type FrameTree = { frame: { id: string }, childFrames?: FrameTree[] };
+  type EvaluateResult = { frameId: string | number, result: { value: unknown } | { exception: { name: string, message: string, stack?: string } } };
+
+  // reports whether the csp of the frames of a tab lets the scripts injected in their main world use eval
+  async function canEvalInMainWorld(tabId: number, allFrames: boolean): Promise<boolean> {
+    try {
+      const results = await browser.scripting.executeScript({
+        target: { tabId, allFrames },
+        func: injectables.canEval,
+        world: "MAIN",
+      });
+      return results.every((res) => res.result === true);
+    } catch {
+      return false;
+    }
+  }
+
+  // evaluates code through the devtools protocol, for the scripts the csp of the page or of the extension doesn't let
+  // the scripting api evaluate. Attaching the debugger shows an infobar, and fails if devtools are open on the tab.
+  async function evaluateWithDebugger(tabId: number, code: string, world: "MAIN" | "ISOLATED", allFrames: boolean): Promise<EvaluateResult[]> {
+    const target = { tabId };
+    const alreadyAttached = attachedTabs.has(tabId);
+    if (!alreadyAttached) {
+      await browser.debugger.attach(target, "1.3");
+    }
+
+    try {
+      const { frameTree } = await browser.debugger.sendCommand(target, "Page.getFrameTree") as { frameTree: FrameTree };
+      const frames: string[] = [];
+      const walk = (node: FrameTree) => {
+        frames.push(node.frame.id);
+        if (allFrames) {
+          node.childFrames?.forEach(walk);
+        }
+      }
+      walk(frameTree);
+
+      // the main world of a frame is its default context, which is reported when the runtime domain is enabled.
+      // The domain is disabled once the contexts are known, so that the runtime events of the page aren't reported to
+      // the debugger sessions of the tab.
+      const contexts = new Map<string, number>();
+      if (world === "MAIN" && allFrames) {
+        const listener = (source: { tabId?: number }, method: string, params?: any) => {
+          if (source.tabId === tabId && method === "Runtime.executionContextCreated" && params.context.auxData?.isDefault) {
+            contexts.set(params.context.auxData.frameId, params.context.id);
+          }
+        };
+
+        browser.debugger.onEvent.addListener(listener);
+        try {
+          await browser.debugger.sendCommand(target, "Runtime.enable");
+        } finally {
+          browser.debugger.onEvent.removeListener(listener);
+          await browser.debugger.sendCommand(target, "Runtime.disable").catch(() => { });
+        }
+      }
+
+      const results: EvaluateResult[] = [];
+      for (const frameId of frames) {
+        let contextId: number | undefined;
+        if (world === "ISOLATED") {
+          ({ executionContextId: contextId } = await browser.debugger.sendCommand(target, "Page.createIsolatedWorld", { frameId, worldName: "tweety" }) as { executionContextId: number });
+        } else if (frameId !== frameTree.frame.id) {
+          contextId = contexts.get(frameId);
+          if (contextId === undefined) {
+            continue;
+          }
+        }
+
+        const { result, exceptionDetails } = await browser.debugger.sendCommand(target, "Runtime.evaluate", {
+          expression: code,
+          contextId,
+          awaitPromise: true,
+          returnByValue: true,
+          userGesture: true,
+        }) as { result: { value?: unknown }, exceptionDetails?: { text: string, exception?: { className?: string, description?: string, value?: unknown } } };
+
+        if (exceptionDetails) {
+          // errors are described by their stack, other thrown values by their value
+          const { className, description, value } = exceptionDetails.exception ?? {};
+          const name = className ?? "Error";
+          const stack = description ?? (value !== undefined ? String(value) : exceptionDetails.text);
+          const [firstLine] = stack.split("\n");
+          results.push({
+            frameId,
+            result: {
+              exception: {
+                name,
+                message: firstLine.startsWith(`${name}: `) ? firstLine.slice(name.length + 2) : firstLine,
+                stack,
+              }
+            }
+          });
+          continue;
+        }
+
+        results.push({ frameId, result: { value: result.value ?? null } });
+      }
+
+      return results;
+    } finally {
+      if (!alreadyAttached) {
+        await browser.debugger.detach(target);
+      }
+    }
+  }
+
   function initialize(port: Browser.runtime.Port, browserId: string) {
     return new Promise((resolve) => {
       const requestId = crypto.randomUUID();
@@ -96,27 +328,185 @@ export default defineBackground(() => {
 
 
 
+  // sends a request to the native host, for methods implemented by the host itself
+  async function callNativeHost<T>(method: string, params: unknown): Promise<T> {
+    const port = await getNativePort();
+    if (!port) {
+      throw new Error("Native host is not connected");
+    }
+
+    const id = crypto.randomUUID();
+    return new Promise((resolve, reject) => {
+      const listener = (message: unknown) => {
+        if (!isJsonRpcResponse(message) || message.id !== id) {
+          return;
+        }
+
+        port.onMessage.removeListener(listener);
+        if (message.error) {
+          reject(new Error(message.error.message));
+          return;
+        }
+
+        resolve(message.result as T);
+      };
+
+      port.onMessage.addListener(listener);
+      port.postMessage({ jsonrpc: "2.0", id, method, params });
+    });
+  }
+
+  // Bookmark commands point to the run page, as browsers do not let extensions handle custom url schemes:
+  // /run.html?command=<name>&arg=<arg>&output=notification|terminal
+  const runPageURL = browser.runtime.getURL("/run.html");
+
+  // the run page asks for its command once, when it is opened from a bookmark, so that reloading or restoring it doesn't
+  // run the command again
+  async function handleRunPage(tabId: number, pageURL: string) {
+    const url = new URL(pageURL);
+    const command = url.searchParams.get("command");
+    if (!command) {
+      return;
+    }
+
+    try {
+      await runBookmarkCommand(tabId, command, url.searchParams);
+    } catch (err) {
+      console.error("Failed to run bookmark command:", err);
+      await browser.notifications.create({
+        type: "basic",
+        iconUrl: browser.runtime.getURL("/icon/128.png"),
+        title: `Failed to run ${command}`,
+        message: (err as Error).message,
+      });
+    }
+  }
+
+  async function runBookmarkCommand(tabId: number, command: string, searchParams: URLSearchParams) {
+    const args = searchParams.getAll("arg");
+
+    // go back to the page the bookmark was opened from, to run the command in its context
+    const env: Record<string, string> = {};
+    const tab = await restorePreviousPage(tabId);
+    if (tab) {
+      env.TWEETY_TAB_ID = String(tabId);
+      env.TWEETY_TAB_URL = tab.url ?? "";
+      env.TWEETY_TAB_TITLE = tab.title ?? "";
+      env.TWEETY_SELECTION = await getSelectionText(tabId);
+    } else {
+      // the bookmark was opened in a new tab
+      await browser.tabs.remove(tabId);
+    }
+
+    if (searchParams.get("output") === "terminal") {
+      const terminalURL = new URL(browser.runtime.getURL("/terminal.html"));
+      terminalURL.searchParams.set("command", command);
+      for (const arg of args) {
+        terminalURL.searchParams.append("arg", arg);
+      }
+      for (const [key, value] of Object.entries(env)) {
+        terminalURL.searchParams.append("env", `${key}=${value}`);
+      }
+
+      await browser.tabs.create({ url: terminalURL.toString(), active: true });
+      return;
+    }
+
+    const { output, exitCode } = await callNativeHost<{ output: string, exitCode: number }>("commands.run", { command, args, env });
+    await browser.notifications.create({
+      type: "basic",
+      iconUrl: browser.runtime.getURL("/icon/128.png"),
+      title: exitCode === 0 ? command : `${command} failed with exit code ${exitCode}`,
+      message: output.trim() || "Command completed",
+    });
+  }
+
+  async function restorePreviousPage(tabId: number): Promise<Browser.tabs.Tab | null> {
+    try {
+      await browser.tabs.goBack(tabId);
+    } catch {
+      return null;
+    }
+
+    // the previous page is usually restored from the back/forward cache, with its selection
+    for (let i = 0; i < 50; i++) {
+      const tab = await browser.tabs.get(tabId);
+      if (tab.status === "complete" && !tab.url?.startsWith(runPageURL)) {
+        return tab;
+      }
+
+      await new Promise((resolve) => setTimeout(resolve, 100));
+    }
+
+    return null;
+  }
+
+  async function getSelectionText(tabId: number): Promise<string> {
+    try {
+      const [res] = await browser.scripting.executeScript({
+        target: { tabId },
+        func: () => window.getSelection()?.toString() ?? "",
+      });
+      return res?.result ?? "";
+    } catch {
+      // pages of the browser can't be scripted
+      return "";
+    }
+  }
+
   // should not be async, else side panel will not open when invoked from the keyboard shortcut
   async function handleCommand(commandId: string, input?: unknown) {
     if (commandId === 'openInNewTab') {
       await browser.tabs.create({
-        url: browser.runtime.getURL("/terminal.html"),
+        url: await getTerminalURL(),
         active: true,
       });
     } else if (commandId === 'openInNewWindow') {
       await browser.windows.create({
-        url: browser.runtime.getURL("/terminal.html"),
+        url: await getTerminalURL(),
         focused: true,
       });
     }
   }
 
-  browser.contextMenus.onClicked.addListener(async (info) => {
+  // returns the url of a new terminal, starting in the working directory of the focused terminal tab if any
+  async function getTerminalURL(): Promise<string> {
+    const url = new URL(browser.runtime.getURL("/terminal.html"));
+
+    const tabId = await getActiveTabId();
+    if (tabId === undefined) {
+      return url.toString();
+    }
+
+    try {
+      // a tab with split panes has several ttys, the focused one is used
+      const ttys = await callNativeHost<{ tabId?: number, cwd?: string, focused: boolean }[]>("tty.list", {});
+      const cwd = ttys.find((tty) => tty.tabId === tabId && tty.focused)?.cwd;
+      if (cwd) {
+        url.searchParams.set("cwd", cwd);
+      }
+    } catch (err) {
+      console.warn("Failed to get the working directory of the terminal:", err);
+    }
+
+    return url.toString();
+  }
+
+  browser.contextMenus.onClicked.addListener(async (info, tab) => {
     if (typeof info.menuItemId !== 'string') {
       console.warn("Invalid menuItemId:", info.menuItemId);
       return;
     }
 
+    // the side panel shows the focused terminal of the tab, it is opened before any await,
+    // as it can only be opened in response to a user gesture. It is set for the tab only, the side panel of the other
+    // tabs keeps showing a new terminal.
+    if (info.menuItemId === 'openInSidePanel' && tab?.id !== undefined) {
+      browser.sidePanel.setOptions({ tabId: tab.id, path: `/popup.html?tab=${tab.id}`, enabled: true });
+      browser.sidePanel.open({ tabId: tab.id });
+      return;
+    }
+
     await handleCommand(info.menuItemId, {
       linkUrl: info.linkUrl,
       srcUrl: info.srcUrl,
@@ -145,6 +535,15 @@ export default defineBackground(() => {
       title: 'Open in New Window',
       contexts: ['all'],
     });
+
+    if (browser.sidePanel) {
+      browser.contextMenus.create({
+        id: 'openInSidePanel',
+        title: 'Open in Side Panel',
+        contexts: ['all'],
+        documentUrlPatterns: [browser.runtime.getURL("/terminal.html*")],
+      });
+    }
   }
 
   function registerHandlers(nativePort: Browser.runtime.Port) {
@@ -153,6 +552,14 @@ export default defineBackground(() => {
         return;
       }
 
+      // notifications of the host are meant for the terminal pages
+      if (message.id === undefined) {
+        browser.runtime.sendMessage(message).catch(() => {
+          // no terminal page is open
+        });
+        return;
+      }
+
       const { id, method, params } = message;
 
       // Helper to send JSON-RPC response
@@ -229,9 +636,65 @@ export default defineBackground(() => {
             sendResponse(null);
             break;
           case "tabs.captureVisibleTab":
-            const capturedTab = await browser.tabs.captureVisibleTab();
+            const capturedTab = await browser.tabs.captureVisibleTab(params[0] ?? undefined, params[1] ?? {});
             sendResponse(capturedTab);
             break;
+          case "tabs.printToPDF": {
+            const tabId = params[0] ?? await getActiveTabId();
+            if (!tabId) {
+              sendError({ code: -32602, message: "No active tab found" });
+              return;
+            }
+
+            const { fileName, ...options } = params[1] ?? {};
+            if (browser.debugger) {
+              const target = { tabId };
+              const alreadyAttached = attachedTabs.has(tabId);
+              if (!alreadyAttached) {
+                await browser.debugger.attach(target, "1.3");
+              }
+
+              try {
+                const { data } = await browser.debugger.sendCommand(target, "Page.printToPDF", options) as { data: string };
+                sendResponse({ data });
+              } finally {
+                if (!alreadyAttached) {
+                  await browser.debugger.detach(target);
+                }
+              }
+              break;
+            }
+
+            // firefox has no debugger api, but can save the pdf to the downloads directory
+            // @ts-ignore
+            if (browser.tabs.saveAsPDF) {
+              if (!fileName) {
+                sendError({ code: -32602, message: "This browser saves PDFs to its downloads directory, use --output with a file name without a directory" });
+                return;
+              }
+
+              await browser.tabs.update(tabId, { active: true });
+              // @ts-ignore
+              const status = await browser.tabs.saveAsPDF({
+                toFileName: fileName,
+                orientation: options.landscape ? 1 : 0,
+                paperWidth: options.paperWidth,
+                paperHeight: options.paperHeight,
+                paperSizeUnit: 0,
+                marginTop: options.marginTop,
+                marginBottom: options.marginBottom,
+                marginLeft: options.marginLeft,
+                marginRight: options.marginRight,
+                showBackgroundColors: options.printBackground,
+                showBackgroundImages: options.printBackground,
+              });
+              sendResponse({ status });
+              break;
+            }
+
+            sendError({ code: -32601, message: "Printing to PDF is not supported by this browser" });
+            break;
+          }
           case "tabs.update":
             const resp = await browser.tabs.update(params[0], params[1]);
             sendResponse(resp);
@@ -248,31 +711,189 @@ export default defineBackground(() => {
             await browser.tabs.goBack(params[0]);
             sendResponse(null);
             break;
-          case "tabs.print":
-            try {
-              let targetTabId: number;
+          case "scripting.executeScript": {
+            const { target, func, args, world } = params[0];
+            const injectable = injectables[func as keyof typeof injectables];
+            if (!injectable) {
+              sendError({ code: -32602, message: `Unknown function: ${func}` });
+              return;
+            }
 
-              if (params.length === 0) {
-                const currentTab = await browser.tabs.query({ active: true, lastFocusedWindow: true });
-                if (currentTab.length === 0 || !currentTab[0].id) {
-                  sendError({ code: -32602, message: "No active tab found" });
-                  return;
-                }
-                targetTabId = currentTab[0].id;
-              } else {
-                targetTabId = params[0];
-              }
+            const tabId = target?.tabId ?? await getActiveTabId();
+            if (!tabId) {
+              sendError({ code: -32602, message: "No active tab found" });
+              return;
+            }
 
-              const results = await browser.scripting.executeScript({
-                target: { tabId: targetTabId },
-                func: () => document.documentElement.outerHTML,
-              });
-              console.log("Tab content retrieved for printing:", results[0].result);
-              sendResponse(results[0].result);
-            } catch (error) {
-              sendError({ code: -32000, message: `Failed to get tab content: ${(error as Error).message}` });
+            const results = await browser.scripting.executeScript({
+              target: { tabId, allFrames: target?.allFrames },
+              func: injectable,
+              args: args || [],
+              world,
+            });
+
+            const failed = results.find((res) => typeof res.result === "object" && res.result !== null && "exception" in res.result);
+            if (failed) {
+              const { exception } = failed.result as { exception: { name: string, message: string, stack?: string } };
+              sendError({ code: -32000, message: `${exception.name}: ${exception.message}`, data: { ...exception, frameId: failed.frameId } });
+              return;
+            }
+
+            sendResponse(results);
+            break;
+          }
+          case "tabs.evaluate": {
+            const { tabId: requestedTabId, code, world, allFrames } = params[0];
+            const tabId = requestedTabId ?? await getActiveTabId();
+            if (!tabId) {
+              sendError({ code: -32602, message: "No active tab found" });
+              return;
+            }
+
+            // the code is evaluated by an injected script when eval is allowed, which is the case in the main world of
+            // pages without a strict csp, and in the content scripts of firefox. Chromium browsers evaluate it through
+            // the debugger api otherwise, the frames are checked first so that the code doesn't run twice.
+            const useDebugger = browser.debugger && (world === "ISOLATED" || !await canEvalInMainWorld(tabId, allFrames));
+            const results: EvaluateResult[] = useDebugger
+              ? await evaluateWithDebugger(tabId, code, world, allFrames)
+              : await browser.scripting.executeScript({
+                target: { tabId, allFrames },
+                func: injectables.evaluate,
+                args: [code],
+                world,
+              }) as EvaluateResult[];
+
+            const failed = results.find((res) => typeof res.result === "object" && res.result !== null && "exception" in res.result);
+            if (failed) {
+              const { exception } = failed.result as { exception: { name: string, message: string, stack?: string } };
+              sendError({ code: -32000, message: `${exception.name}: ${exception.message}`, data: { ...exception, frameId: failed.frameId } });
+              return;
+            }
+
+            sendResponse(results);
+            break;
+          }
+          case "tabs.group":
+            const groupId = await browser.tabs.group(params[0]);
+            sendResponse(groupId);
+            break;
+          case "tabs.ungroup":
+            await browser.tabs.ungroup(params[0]);
+            sendResponse(null);
+            break;
+          case "tabGroups.query":
+            const tabGroups = await browser.tabGroups.query(params[0]);
+            sendResponse(tabGroups);
+            break;
+          case "tabGroups.get":
+            const tabGroup = await browser.tabGroups.get(params[0]);
+            sendResponse(tabGroup);
+            break;
+          case "tabGroups.update":
+            const updatedTabGroup = await browser.tabGroups.update(params[0], params[1]);
+            sendResponse(updatedTabGroup);
+            break;
+          case "tabGroups.move":
+            const movedTabGroup = await browser.tabGroups.move(params[0], params[1]);
+            sendResponse(movedTabGroup);
+            break;
+          case "debugger.attach": {
+            const target = params[0];
+            if (target.tabId && attachedTabs.has(target.tabId)) {
+              sendResponse({ alreadyAttached: true });
+              break;
+            }
+
+            await browser.debugger.attach(target, params[1] ?? "1.3");
+            if (target.tabId) {
+              attachedTabs.add(target.tabId);
             }
+            sendResponse({ alreadyAttached: false });
+            break;
+          }
+          case "debugger.sendCommand":
+            const commandResult = await browser.debugger.sendCommand(params[0], params[1], params[2]);
+            sendResponse(commandResult ?? {});
+            break;
+          case "debugger.detach":
+            await browser.debugger.detach(params[0]);
+            if (params[0].tabId) {
+              attachedTabs.delete(params[0].tabId);
+            }
+            sendResponse(null);
+            break;
+          case "debugger.getTargets":
+            const targets = await browser.debugger.getTargets();
+            sendResponse(targets);
+            break;
+          case "cookies.getAll":
+            const cookies = await browser.cookies.getAll(params[0]);
+            sendResponse(cookies);
+            break;
+          case "cookies.get":
+            const cookie = await browser.cookies.get(params[0]);
+            sendResponse(cookie ?? null);
+            break;
+          case "cookies.set":
+            const newCookie = await browser.cookies.set(params[0]);
+            sendResponse(newCookie ?? null);
+            break;
+          case "cookies.remove":
+            const removedCookie = await browser.cookies.remove(params[0]);
+            sendResponse(removedCookie ?? null);
             break;
+          case "downloads.download":
+            const downloadId = await browser.downloads.download(params[0]);
+            sendResponse(downloadId);
+            break;
+          case "downloads.search":
+            const downloads = await browser.downloads.search(params[0]);
+            sendResponse(downloads);
+            break;
+          case "downloads.pause":
+            await browser.downloads.pause(params[0]);
+            sendResponse(null);
+            break;
+          case "downloads.resume":
+            await browser.downloads.resume(params[0]);
+            sendResponse(null);
+            break;
+          case "downloads.cancel":
+            await browser.downloads.cancel(params[0]);
+            sendResponse(null);
+            break;
+          case "downloads.show":
+            browser.downloads.show(params[0]);
+            sendResponse(null);
+            break;
+          case "downloads.erase":
+            const erasedIds = await browser.downloads.erase(params[0]);
+            sendResponse(erasedIds);
+            break;
+          case "action.setBadgeText":
+            // firefox still uses manifest v2
+            await (browser.action ?? browser.browserAction).setBadgeText(params[0]);
+            sendResponse(null);
+            break;
+          case "action.setBadgeBackgroundColor":
+            await (browser.action ?? browser.browserAction).setBadgeBackgroundColor(params[0]);
+            sendResponse(null);
+            break;
+          case "runtime.getURL":
+            sendResponse(browser.runtime.getURL(params[0]));
+            break;
+          case "clipboard.read": {
+            const { mimeType } = params[0] ?? {};
+            const res = await sendClipboardMessage({ target: "offscreen", action: "read", mimeType: mimeType || "text/plain" });
+            sendResponse({ data: res.data ?? "" });
+            break;
+          }
+          case "clipboard.write": {
+            const { data, mimeType } = params[0] ?? {};
+            await sendClipboardMessage({ target: "offscreen", action: "write", data, mimeType: mimeType || "text/plain" });
+            sendResponse({});
+            break;
+          }
           case "windows.getAll":
             const windows = await browser.windows.getAll();
             sendResponse(windows);
@@ -305,6 +926,25 @@ export default defineBackground(() => {
             const historyItems = await browser.history.search(params[0]);
             sendResponse(historyItems);
             break;
+          case "history.getVisits":
+            sendResponse(await browser.history.getVisits(params[0]));
+            break;
+          case "history.addUrl":
+            await browser.history.addUrl(params[0]);
+            sendResponse({});
+            break;
+          case "history.deleteUrl":
+            await browser.history.deleteUrl(params[0]);
+            sendResponse({});
+            break;
+          case "history.deleteRange":
+            await browser.history.deleteRange(params[0]);
+            sendResponse({});
+            break;
+          case "history.deleteAll":
+            await browser.history.deleteAll();
+            sendResponse({});
+            break;
           case "bookmarks.getTree":
             const bookmarksTree = await browser.bookmarks.getTree();
             sendResponse(bookmarksTree);
@@ -329,6 +969,19 @@ export default defineBackground(() => {
             await browser.bookmarks.remove(params[0]);
             sendResponse(null);
             break;
+          case "bookmarks.getChildren":
+            sendResponse(await browser.bookmarks.getChildren(params[0]));
+            break;
+          case "bookmarks.getSubTree":
+            sendResponse(await browser.bookmarks.getSubTree(params[0]));
+            break;
+          case "bookmarks.move":
+            sendResponse(await browser.bookmarks.move(params[0], params[1]));
+            break;
+          case "bookmarks.removeTree":
+            await browser.bookmarks.removeTree(params[0]);
+            sendResponse(null);
+            break;
           case "notifications.create":
             if (params.length == 2) {
               const res = await browser.notifications.create(params[0], params[1]);
@@ -344,6 +997,15 @@ export default defineBackground(() => {
             console.error("Invalid params for notifications.create:", params);
             sendError({ code: -32602, message: "Invalid params for notifications.create" });
             break;
+          case "notifications.update":
+            sendResponse(await browser.notifications.update(params[0], params[1]));
+            break;
+          case "notifications.clear":
+            sendResponse(await browser.notifications.clear(params[0]));
+            break;
+          case "notifications.getAll":
+            sendResponse(await browser.notifications.getAll());
+            break;
           default:
             console.error("Method not found:", method);
             sendError({ code: -32601, message: `Method not found: ${method}` });
@@ -363,6 +1025,23 @@ export default defineBackground(() => {
       return false; // Ignore messages from unknown senders
     }
 
+    // the command is read from the url of the run page, not from the message
+    if (msg.method === "bookmark.run") {
+      if (sender.tab?.id === undefined || sender.frameId !== 0 || !sender.url?.startsWith(runPageURL)) {
+        console.warn("Received bookmark.run from an unexpected page:", sender.url);
+        return false;
+      }
+
+      sendResponse(true);
+      handleRunPage(sender.tab.id, sender.url);
+      return false;
+    }
+
+    // let the host know which tab owns the terminal, to focus it from notifications
+    if (msg.method === "tty.create" && sender.tab?.id) {
+      msg.params = { ...msg.params, tabId: sender.tab.id };
+    }
+
     getNativePort().then((nativePort) => {
       if (!nativePort) {
         return sendResponse({
@@ -400,6 +1079,11 @@ export default defineBackground(() => {
     return true
   })
 
+  async function getActiveTabId(): Promise<number | undefined> {
+    const [activeTab] = await browser.tabs.query({ active: true, lastFocusedWindow: true });
+    return activeTab?.id;
+  }
+
   function isJsonRpcRequest(message: unknown): message is JSONRPCRequest {
     if (typeof message !== "object" || message === null) {
       return false;
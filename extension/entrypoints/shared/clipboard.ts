@@ -0,0 +1,57 @@
+// Clipboard access relying on copy/paste events, which works in documents without focus,
+// unlike the async clipboard api. It requires the clipboardRead and clipboardWrite permissions.
+
+export type ClipboardMessage = {
+    target: "offscreen";
+    action: "read";
+    mimeType: string;
+} | {
+    target: "offscreen";
+    action: "write";
+    mimeType: string;
+    data: string;
+}
+
+export function writeClipboard(data: string, mimeType: string) {
+    const onCopy = (event: ClipboardEvent) => {
+        event.preventDefault();
+        event.clipboardData?.setData(mimeType, data);
+    };
+
+    document.addEventListener("copy", onCopy);
+    try {
+        if (!document.execCommand("copy")) {
+            throw new Error("Failed to write to the clipboard");
+        }
+    } finally {
+        document.removeEventListener("copy", onCopy);
+    }
+}
+
+export function readClipboard(mimeType: string): string {
+    let data = "";
+    const onPaste = (event: ClipboardEvent) => {
+        event.preventDefault();
+        data = event.clipboardData?.getData(mimeType) ?? "";
+    };
+
+    document.addEventListener("paste", onPaste);
+    try {
+        if (!document.execCommand("paste")) {
+            throw new Error("Failed to read from the clipboard");
+        }
+    } finally {
+        document.removeEventListener("paste", onPaste);
+    }
+
+    return data;
+}
+
+export function handleClipboardMessage(message: ClipboardMessage): { data?: string } {
+    if (message.action === "read") {
+        return { data: readClipboard(message.mimeType) };
+    }
+
+    writeClipboard(message.data, message.mimeType);
+    return {};
+}
@@ -0,0 +1,16 @@
+import { ClipboardMessage, handleClipboardMessage } from "./clipboard";
+
+// The offscreen document gives the background service worker access to DOM apis.
+browser.runtime.onMessage.addListener((msg: ClipboardMessage, _sender, sendResponse) => {
+    if (msg?.target !== "offscreen") {
+        return false;
+    }
+
+    try {
+        sendResponse(handleClipboardMessage(msg));
+    } catch (error) {
+        sendResponse({ error: (error as Error).message });
+    }
+
+    return false;
+});
@@ -12,19 +12,84 @@ type JSONRPCRequestBase<M extends string, P extends Record<string, any> | undefi
     params?: P
 }
 
+// the size of a tty is the smallest of the sizes of its clients
 export type RequestResizeTTY = JSONRPCRequestBase<"tty.resize", {
     tty: string;
+    client: string;
     cols: number;
     rows: number;
 }>
 
-export type RequestCreateTTY = JSONRPCRequestBase<"tty.create", {
+export type TTYParams = {
     mode: "app";
     app: string;
     args: string[];
     cwd?: string;
+} | {
+    mode: "exec";
+    nonce: string;
+} | {
+    mode?: undefined;
+    cwd?: string;
+} | {
+    mode: "command";
+    command: string;
+    args: string[];
+    env: Record<string, string>;
+} | {
+    // show a running tty, which stays open in the other pages showing it
+    mode: "attach";
+    tty: string;
+} | {
+    // show the tty of the focused pane of a tab
+    mode: "attach";
+    tab: number;
+}
+
+// layout and pane are set for the tty of a pane of a terminal page
+export type RequestCreateTTY = JSONRPCRequestBase<"tty.create", TTYParams & {
+    layout?: string;
+    pane?: string;
+    // record the tty in the recordings directory
+    record?: boolean;
+}>
+
+// a layout is either a pane, or a split of its children, sizes are percentages
+export type LayoutNode = {
+    pane: string;
+    tty?: string;
+    cwd?: string;
+} | {
+    pane?: undefined;
+    direction: "horizontal" | "vertical";
+    sizes: number[];
+    children: LayoutNode[];
+}
+
+export type Layout = {
+    id: string;
+    root: LayoutNode | null;
+    focus: string;
+}
+
+export type RequestGetLayout = JSONRPCRequestBase<"tty.layout", {
+    id: string;
+    create: boolean;
+}>
+
+export type RequestFocusPane = JSONRPCRequestBase<"tty.focus", {
+    id: string;
+    pane: string;
 }>
 
+export type RequestClosePane = JSONRPCRequestBase<"tty.closePane", {
+    id: string;
+    pane: string;
+}>
+
+// sent by the host when a layout changes
+export type NotificationLayoutChanged = JSONRPCRequestBase<"tty.layoutChanged", Layout>
+
 export type RequestGetXtermConfig = JSONRPCRequestBase<"xterm.getConfig", {
     variant?: "light" | "dark";
 }>;
@@ -60,8 +125,8 @@ export type ResponseGetXtermConfig = JSONRPCResponseBase
 export type ResponseCreateTTY = JSONRPCResponseBase<{
     id: string;
     url: string;
+    // title of the command, for the exec mode
+    title?: string;
 }>
 
-
-
-
+export type ResponseLayout = JSONRPCResponseBase<Layout>
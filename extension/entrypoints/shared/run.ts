@@ -0,0 +1,24 @@
+// Bookmark commands are run by the background script, which navigates back to the previous page.
+// The command is only requested when the page is opened from the bookmark, reloading the page, going back to it or
+// restoring it with the browser session doesn't run it again.
+function main() {
+    const [navigation] = performance.getEntriesByType("navigation") as PerformanceNavigationTiming[];
+    if (navigation && navigation.type !== "navigate") {
+        return;
+    }
+
+    // the session storage of a tab is restored with it
+    if (sessionStorage.getItem("tweety-run")) {
+        return;
+    }
+    sessionStorage.setItem("tweety-run", "1");
+
+    browser.runtime.sendMessage({
+        jsonrpc: "2.0",
+        method: "bookmark.run",
+    }).catch((err) => {
+        console.error("Failed to run bookmark command:", err);
+    });
+}
+
+main();
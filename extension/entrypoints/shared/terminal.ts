@@ -1,9 +1,21 @@
-import { Terminal } from "@xterm/xterm";
+import { IDisposable, Terminal } from "@xterm/xterm";
 import { FitAddon } from "@xterm/addon-fit";
 import { AttachAddon } from "@xterm/addon-attach";
 import { WebglAddon } from "@xterm/addon-webgl";
 import { WebLinksAddon } from "@xterm/addon-web-links";
-import { RequestCreateTTY, RequestGetXtermConfig, RequestResizeTTY, ResponseCreateTTY, ResponseGetXtermConfig } from "./rpc";
+import { Layout, LayoutNode, NotificationLayoutChanged, RequestClosePane, RequestCreateTTY, RequestFocusPane, RequestGetLayout, RequestGetXtermConfig, RequestResizeTTY, ResponseCreateTTY, ResponseGetXtermConfig, ResponseLayout, TTYParams } from "./rpc";
+
+type Pane = {
+    id: string;
+    element: HTMLDivElement;
+    terminal: Terminal;
+    fitAddon: FitAddon;
+    opened: boolean;
+    ws?: WebSocket;
+    title?: string;
+    // the addons and listeners of the connection to the tty, replaced when the pane attaches to it again
+    disposables: IDisposable[];
+}
 
 async function main() {
     const anchor = document.getElementById("terminal");
@@ -26,9 +38,10 @@ async function main() {
         return;
     }
 
-    const requestId = crypto.randomUUID();
+    const xtermConfig = xtermResp.result;
+
     const searchParams = new URLSearchParams(window.location.search);
-    let params: RequestCreateTTY["params"]
+    let params: TTYParams | undefined
     if (searchParams.has("app")) {
         params = {
             mode: "app",
@@ -36,67 +49,313 @@ async function main() {
             args: searchParams.getAll("arg"),
             cwd: searchParams.get("cwd") || undefined,
         }
+    } else if (searchParams.has("command")) {
+        // bookmark commands pass the context of the tab as KEY=VALUE env params
+        params = {
+            mode: "command",
+            command: searchParams.get("command")!,
+            args: searchParams.getAll("arg"),
+            env: Object.fromEntries(searchParams.getAll("env").map((entry) => {
+                const index = entry.indexOf("=");
+                return [entry.slice(0, index), entry.slice(index + 1)];
+            })),
+        }
+    } else if (searchParams.get("mode") === "exec") {
+        params = {
+            mode: "exec",
+            nonce: searchParams.get("nonce") ?? "",
+        }
+    } else if (searchParams.has("tty")) {
+        params = {
+            mode: "attach",
+            tty: searchParams.get("tty")!,
+        }
+    } else if (searchParams.has("tab")) {
+        params = {
+            mode: "attach",
+            tab: Number(searchParams.get("tab")),
+        }
+    } else if (searchParams.has("cwd")) {
+        params = {
+            cwd: searchParams.get("cwd")!,
+        }
     }
 
-    const resp = await browser.runtime.sendMessage<RequestCreateTTY, ResponseCreateTTY>({
+    // the layout of the page is kept by the host, its ID is stored in the url so that a reload restores the panes
+    const layoutResp = await browser.runtime.sendMessage<RequestGetLayout, ResponseLayout>({
         jsonrpc: "2.0",
-        id: requestId,
-        method: "tty.create",
-        params
+        id: crypto.randomUUID(),
+        method: "tty.layout",
+        params: {
+            id: searchParams.get("layout") ?? "",
+            create: true,
+        }
     })
 
-    if ("error" in resp) {
-        console.error("Error creating TTY:", resp.error);
-        globalThis.document.body.innerHTML = `<h1>Error: ${resp.error.message}</h1>`;
+    if ("error" in layoutResp) {
+        console.error("Error getting layout:", layoutResp.error);
+        globalThis.document.body.innerHTML = `<h1>Error: ${layoutResp.error.message}</h1>`;
         return;
     }
 
-    const terminal = new Terminal(xtermResp.result);
+    let layout: Layout = layoutResp.result;
+
+    // a new layout has a single pane, which runs the program requested by the url
+    let initialPane: string | undefined
+    if (searchParams.get("layout") !== layout.id) {
+        initialPane = layout.focus;
+
+        const url = new URL(window.location.href);
+        url.searchParams.set("layout", layout.id);
+        history.replaceState(null, "", url);
+    }
+
+    const panes = new Map<string, Pane>();
+
+    const updateTitle = () => {
+        const title = panes.get(layout.focus)?.title;
+        if (title) {
+            document.title = `${title}  |  Tweety`
+        }
+    }
+
+    const createPane = (id: string): Pane => {
+        const element = document.createElement("div");
+        element.className = "pane";
+
+        const terminal = new Terminal(xtermConfig);
+        const fitAddon = new FitAddon();
+        terminal.loadAddon(fitAddon);
+        terminal.loadAddon(new WebLinksAddon());
+
+        const pane: Pane = { id, element, terminal, fitAddon, opened: false, disposables: [] };
+        panes.set(id, pane);
+        return pane;
+    }
+
+    const connectPane = async (pane: Pane, params?: TTYParams, record?: boolean): Promise<boolean> => {
+        const resp = await browser.runtime.sendMessage<RequestCreateTTY, ResponseCreateTTY>({
+            jsonrpc: "2.0",
+            id: crypto.randomUUID(),
+            method: "tty.create",
+            params: {
+                ...params,
+                layout: layout.id,
+                pane: pane.id,
+                record,
+            }
+        })
+
+        if ("error" in resp) {
+            console.error("Error creating TTY:", resp.error);
+            pane.terminal.write(`Error: ${resp.error.message}\r\n`);
+            return false;
+        }
+
+        for (const disposable of pane.disposables.splice(0)) {
+            disposable.dispose();
+        }
+
+        // the page identifies itself, as a tty can be shown in several pages at once
+        const client = crypto.randomUUID();
+        const wsURL = new URL(resp.result.url);
+        wsURL.searchParams.set("client", client);
+
+        const ws = new WebSocket(wsURL);
+        pane.ws = ws;
+        const attachAddon = new AttachAddon(ws);
+        pane.terminal.loadAddon(attachAddon);
+        pane.disposables.push(attachAddon);
+
+        const resize = async (cols: number, rows: number) => {
+            await browser.runtime.sendMessage<RequestResizeTTY>({
+                jsonrpc: "2.0",
+                method: "tty.resize",
+                params: {
+                    tty: resp.result.id,
+                    client,
+                    cols,
+                    rows,
+                },
+            })
+        }
+        pane.disposables.push(pane.terminal.onResize(({ cols, rows }) => resize(cols, rows)));
+        await resize(pane.terminal.cols, pane.terminal.rows);
+
+        // the host closes the connection with a normal closure once the program exits, other closures happen while it
+        // still runs, when the page is too slow to keep up with its output
+        let connected = false;
+        ws.onopen = () => {
+            connected = true;
+        };
+        ws.onclose = (event) => {
+            if (event.code === 1000 || !connected) {
+                closePane(pane);
+                return;
+            }
+
+            reconnectPane(pane, resp.result.id);
+        };
+
+        if (resp.result.title) {
+            pane.title = resp.result.title;
+            updateTitle();
+        } else {
+            pane.disposables.push(pane.terminal.onTitleChange((title) => {
+                pane.title = title;
+                updateTitle();
+            }));
+        }
+
+        return true;
+    }
+
+    // the tty is shown again from its scrollback, the pane is closed if it is gone
+    const reconnectPane = async (pane: Pane, tty: string) => {
+        pane.terminal.reset();
+        if (!await connectPane(pane, { mode: "attach", tty })) {
+            closePane(pane);
+        }
+    }
+
+    const closePane = async (pane: Pane) => {
+        const resp = await browser.runtime.sendMessage<RequestClosePane, ResponseLayout>({
+            jsonrpc: "2.0",
+            id: crypto.randomUUID(),
+            method: "tty.closePane",
+            params: {
+                id: layout.id,
+                pane: pane.id,
+            }
+        })
 
-    const fitAddon = new FitAddon();
-    terminal.loadAddon(fitAddon);
+        if ("error" in resp) {
+            console.error("Error closing pane:", resp.error);
+            globalThis.close();
+            return;
+        }
+
+        applyLayout(resp.result);
+    }
+
+    const render = () => {
+        const ids = new Set<string>();
+        const build = (node: LayoutNode): HTMLElement => {
+            if (node.pane !== undefined) {
+                ids.add(node.pane);
+                return (panes.get(node.pane) ?? createPane(node.pane)).element;
+            }
 
-    terminal.loadAddon(new WebglAddon());
-    terminal.loadAddon(new WebLinksAddon());
+            const element = document.createElement("div");
+            element.className = `split split-${node.direction}`;
+            node.children.forEach((child, index) => {
+                const childElement = build(child);
+                childElement.style.flex = `${node.sizes[index]} 1 0`;
+                element.appendChild(childElement);
+            });
 
-    const ws = new WebSocket(resp.result.url);
-    const attachAddon = new AttachAddon(ws);
-    terminal.loadAddon(attachAddon);
+            return element;
+        }
+
+        if (layout.root) {
+            anchor.replaceChildren(build(layout.root));
+        }
+
+        for (const [id, pane] of panes) {
+            if (ids.has(id)) {
+                continue;
+            }
+
+            if (pane.ws) {
+                pane.ws.onclose = () => { };
+                pane.ws.close();
+            }
+            pane.terminal.dispose();
+            panes.delete(id);
+        }
+
+        for (const pane of panes.values()) {
+            if (!pane.opened) {
+                pane.opened = true;
+                pane.terminal.open(pane.element);
+                pane.terminal.loadAddon(new WebglAddon());
+                pane.terminal.textarea?.addEventListener("focus", () => focusPane(pane));
+                pane.fitAddon.fit();
+                if (pane.id === initialPane) {
+                    connectPane(pane, params, searchParams.has("record"));
+                } else {
+                    connectPane(pane);
+                }
+                continue;
+            }
+
+            pane.fitAddon.fit();
+        }
+
+        panes.get(layout.focus)?.terminal.focus();
+        updateTitle();
+    }
+
+    // the host is told about the focused pane, so that pane commands target it by default
+    const focusPane = async (pane: Pane) => {
+        if (layout.focus === pane.id) {
+            return;
+        }
 
-    terminal.open(anchor);
-    terminal.onResize(async (size) => {
-        const { cols, rows } = size;
-        await browser.runtime.sendMessage<RequestResizeTTY>({
+        layout.focus = pane.id;
+        updateTitle();
+        await browser.runtime.sendMessage<RequestFocusPane, ResponseLayout>({
             jsonrpc: "2.0",
-            method: "tty.resize",
+            id: crypto.randomUUID(),
+            method: "tty.focus",
             params: {
-                tty: resp.result.id,
-                cols,
-                rows,
-            },
+                id: layout.id,
+                pane: pane.id,
+            }
         })
+    }
+
+    const applyLayout = (newLayout: Layout) => {
+        if (newLayout.id !== layout.id) {
+            return;
+        }
+
+        layout = newLayout;
+        if (!layout.root) {
+            globalThis.close();
+            return;
+        }
+
+        render();
+    }
+
+    browser.runtime.onMessage.addListener((msg: NotificationLayoutChanged) => {
+        if (msg?.method !== "tty.layoutChanged" || !msg.params) {
+            return;
+        }
+
+        applyLayout(msg.params);
     });
-    fitAddon.fit();
+
+    render();
 
     globalThis.onbeforeunload = () => {
-        ws.onclose = () => { }
-        ws.close();
+        for (const pane of panes.values()) {
+            if (pane.ws) {
+                pane.ws.onclose = () => { }
+                pane.ws.close();
+            }
+        }
     };
 
-    ws.onclose = async () => {
-        globalThis.close();
-    }
-
     globalThis.onresize = () => {
-        fitAddon.fit();
+        for (const pane of panes.values()) {
+            pane.fitAddon.fit();
+        }
     };
 
-    terminal.onTitleChange((title) => {
-        document.title = `${title}  |  Tweety`
-    });
-
     globalThis.onfocus = () => {
-        terminal.focus();
+        panes.get(layout.focus)?.terminal.focus();
     };
 
     window.matchMedia("(prefers-color-scheme: dark)").addEventListener("change", async (event) => {
@@ -112,11 +371,10 @@ async function main() {
             return;
         }
 
-
-        terminal.options.theme = resp.result.theme
+        for (const pane of panes.values()) {
+            pane.terminal.options.theme = resp.result.theme
+        }
     });
-
-    terminal.focus();
 }
 
 main();
@@ -10,13 +10,19 @@ export default defineConfig({
         version: process.env.MANIFEST_VERSION || "0.0.0",
         permissions: [
             "tabs",
+            "tabGroups",
             "nativeMessaging",
             "contextMenus",
             "notifications",
             "bookmarks",
+            "clipboardRead",
+            "clipboardWrite",
+            "cookies",
+            "downloads",
             "history",
             "scripting",
-            "storage"
+            "storage",
+            ...(browser == "chrome" ? ["debugger", "offscreen", "sidePanel"] : []),
         ],
         host_permissions: [
             "<all_urls>"
@@ -12,6 +12,7 @@ require (
 	github.com/knadh/koanf/v2 v2.2.0
 	github.com/mattn/go-isatty v0.0.20
 	github.com/spf13/cobra v1.9.1
+	golang.org/x/net v0.40.0
 )
 
 require (
@@ -2,21 +2,28 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/cli/cli/v2/pkg/jsoncolor"
 	"github.com/mattn/go-isatty"
 	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/pomdtr/tweety/internal/markdown"
 	"github.com/spf13/cobra"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 func NewCmdBookmarks() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "bookmark",
 		Aliases: []string{"bookmarks"},
-		PreRunE: func(cmd *cobra.Command, args []string) error {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			if env := os.Getenv("TWEETY_SOCKET"); env == "" {
 				return fmt.Errorf("TWEETY_SOCKET environment variable must be set")
 			}
@@ -33,6 +40,12 @@ func NewCmdBookmarks() *cobra.Command {
 		NewCmdBookmarksCreate(),
 		NewCmdBookmarksUpdate(),
 		NewCmdBookmarksRemove(),
+		NewCmdBookmarksGetChildren(),
+		NewCmdBookmarksMove(),
+		NewCmdBookmarksRemoveTree(),
+		NewCmdBookmarksExport(),
+		NewCmdBookmarksImport(),
+		NewCmdBookmarksAddCommand(),
 	)
 
 	return cmd
@@ -219,3 +232,535 @@ func NewCmdBookmarksRemove() *cobra.Command {
 		},
 	}
 }
+
+func NewCmdBookmarksGetChildren() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get-children <id>",
+		Short: "Get the children of a bookmark folder",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "bookmarks.getChildren", []any{args[0]})
+			if err != nil {
+				return err
+			}
+
+			if resp.Error != nil {
+				os.Stderr.Write(resp.Error)
+				os.Exit(1)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(resp.Result)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+			return nil
+		},
+	}
+}
+
+func NewCmdBookmarksMove() *cobra.Command {
+	var flags struct {
+		parentId string
+		index    int
+	}
+
+	cmd := &cobra.Command{
+		Use:   "move <id>",
+		Short: "Move a bookmark or folder",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			destination := map[string]any{}
+			if flags.parentId != "" {
+				destination["parentId"] = flags.parentId
+			}
+
+			if cmd.Flags().Changed("index") {
+				destination["index"] = flags.index
+			}
+
+			if len(destination) == 0 {
+				return fmt.Errorf("at least one of --parent-id or --index must be provided")
+			}
+
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "bookmarks.move", []any{args[0], destination})
+			if err != nil {
+				return err
+			}
+
+			if resp.Error != nil {
+				os.Stderr.Write(resp.Error)
+				os.Exit(1)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(resp.Result)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.parentId, "parent-id", "", "Destination folder ID")
+	cmd.Flags().IntVar(&flags.index, "index", 0, "Position in the destination folder")
+
+	return cmd
+}
+
+func NewCmdBookmarksRemoveTree() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove-tree <id>",
+		Short: "Remove a bookmark folder and its content",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "bookmarks.removeTree", []any{args[0]}, nil); err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+}
+
+func NewCmdBookmarksExport() *cobra.Command {
+	var flags struct {
+		format string
+		output string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "export [folder-id]",
+		Short: "Export bookmarks",
+		Long: `Export bookmarks, or the content of a folder.
+
+The netscape-html format is understood by every browser. The json format only keeps titles, URLs
+and folders, so that it can be tracked in git and imported back with the import command.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var nodes []bookmarkNode
+			if len(args) > 0 {
+				if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "bookmarks.getSubTree", []any{args[0]}, &nodes); err != nil {
+					return fmt.Errorf("failed to get bookmarks: %w", err)
+				}
+			} else if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "bookmarks.getTree", []any{}, &nodes); err != nil {
+				return fmt.Errorf("failed to get bookmarks: %w", err)
+			}
+
+			if len(nodes) == 0 {
+				return fmt.Errorf("bookmark folder not found")
+			}
+
+			entries := bookmarkEntries(nodes[0].Children)
+
+			var buf bytes.Buffer
+			switch flags.format {
+			case "netscape-html":
+				writeNetscapeBookmarks(&buf, entries)
+			case "json":
+				encoder := json.NewEncoder(&buf)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(entries); err != nil {
+					return fmt.Errorf("failed to encode bookmarks: %w", err)
+				}
+			case "markdown":
+				writeMarkdownBookmarks(&buf, entries, 0)
+			default:
+				return fmt.Errorf("invalid format: %s", flags.format)
+			}
+
+			if flags.output == "" || flags.output == "-" {
+				os.Stdout.Write(buf.Bytes())
+				return nil
+			}
+
+			if err := os.WriteFile(flags.output, buf.Bytes(), 0644); err != nil {
+				return fmt.Errorf("failed to write bookmarks: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.format, "format", "netscape-html", "Format of the export (netscape-html, json, markdown)")
+	cmd.Flags().StringVarP(&flags.output, "output", "o", "", "File to write the export to (- for stdout)")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"netscape-html", "json", "markdown"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func NewCmdBookmarksImport() *cobra.Command {
+	var flags struct {
+		format   string
+		parentId string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import bookmarks",
+		Long: `Import bookmarks from a netscape-html or json export, use - to read from stdin.
+
+Folders are merged with existing folders of the same title, and bookmarks whose URL is already
+present in the destination folder are skipped, so that importing the same file twice is a no-op.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var content []byte
+			var err error
+			if args[0] == "-" {
+				content, err = io.ReadAll(os.Stdin)
+			} else {
+				content, err = os.ReadFile(args[0])
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read bookmarks: %w", err)
+			}
+
+			format := flags.format
+			if format == "" {
+				format = "netscape-html"
+				if strings.HasSuffix(args[0], ".json") {
+					format = "json"
+				}
+			}
+
+			var entries []bookmarkEntry
+			switch format {
+			case "netscape-html":
+				entries, err = parseNetscapeBookmarks(bytes.NewReader(content))
+				if err != nil {
+					return fmt.Errorf("failed to parse bookmarks: %w", err)
+				}
+			case "json":
+				if err := json.Unmarshal(content, &entries); err != nil {
+					return fmt.Errorf("failed to parse bookmarks: %w", err)
+				}
+			default:
+				return fmt.Errorf("invalid format: %s", format)
+			}
+
+			parentId := flags.parentId
+			if parentId == "" {
+				parentId, err = defaultBookmarkFolder()
+				if err != nil {
+					return err
+				}
+			}
+
+			importer := &bookmarkImporter{socketPath: os.Getenv("TWEETY_SOCKET")}
+			if err := importer.importEntries(parentId, entries); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "Imported %d bookmarks, skipped %d duplicates\n", importer.created, importer.skipped)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.format, "format", "", "Format of the file (netscape-html, json), guessed from the extension if omitted")
+	cmd.Flags().StringVar(&flags.parentId, "parent-id", "", "Folder to import the bookmarks in, defaults to the other bookmarks folder")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"netscape-html", "json"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func NewCmdBookmarksAddCommand() *cobra.Command {
+	var flags struct {
+		title    string
+		parentId string
+		terminal bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "add-command <command> [args...]",
+		Short: "Bookmark a command of the command directory",
+		Long: `Bookmark a command of the command directory.
+
+Opening the bookmark runs the command in the context of the current tab, which is exposed through the
+TWEETY_TAB_ID, TWEETY_TAB_URL, TWEETY_TAB_TITLE and TWEETY_SELECTION environment variables.
+The output of the command is shown in a notification, or in a new terminal tab when using --terminal.`,
+		Args: cobra.MinimumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+
+			return NewCmdRun().ValidArgsFunction(cmd, args, toComplete)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := findEntrypoint(commandDir, args[0]); err != nil {
+				return fmt.Errorf("unknown command: %s", args[0])
+			}
+
+			var runPageURL string
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "runtime.getURL", []any{"/run.html"}, &runPageURL); err != nil {
+				return fmt.Errorf("failed to get run page URL: %w", err)
+			}
+
+			query := url.Values{}
+			query.Set("command", args[0])
+			for _, arg := range args[1:] {
+				query.Add("arg", arg)
+			}
+
+			if flags.terminal {
+				query.Set("output", "terminal")
+			}
+
+			title := flags.title
+			if title == "" {
+				title = strings.Join(args, " ")
+			}
+
+			parentId := flags.parentId
+			if parentId == "" {
+				var err error
+				parentId, err = defaultBookmarkFolder()
+				if err != nil {
+					return err
+				}
+			}
+
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "bookmarks.create", []any{map[string]any{
+				"parentId": parentId,
+				"title":    title,
+				"url":      runPageURL + "?" + query.Encode(),
+			}})
+			if err != nil {
+				return err
+			}
+
+			if resp.Error != nil {
+				os.Stderr.Write(resp.Error)
+				os.Exit(1)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(resp.Result)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.title, "title", "", "Bookmark title, defaults to the command line")
+	cmd.Flags().StringVar(&flags.parentId, "parent-id", "", "Parent folder ID, defaults to the other bookmarks folder")
+	cmd.Flags().BoolVar(&flags.terminal, "terminal", false, "Run the command in a new terminal tab instead of showing its output in a notification")
+	cmd.Flags().SetInterspersed(false)
+
+	return cmd
+}
+
+// bookmarkNode mirrors the fields of chrome.bookmarks.BookmarkTreeNode.
+type bookmarkNode struct {
+	ID       string         `json:"id"`
+	ParentID string         `json:"parentId"`
+	Title    string         `json:"title"`
+	URL      string         `json:"url"`
+	Type     string         `json:"type"`
+	Children []bookmarkNode `json:"children"`
+}
+
+// bookmarkEntry is the portable representation of a bookmark, folders have no URL.
+type bookmarkEntry struct {
+	Title    string          `json:"title"`
+	URL      string          `json:"url,omitempty"`
+	Children []bookmarkEntry `json:"children,omitempty"`
+}
+
+func bookmarkEntries(nodes []bookmarkNode) []bookmarkEntry {
+	entries := []bookmarkEntry{}
+	for _, node := range nodes {
+		// firefox separators have a data url
+		if node.Type == "separator" {
+			continue
+		}
+
+		entries = append(entries, bookmarkEntry{
+			Title:    node.Title,
+			URL:      node.URL,
+			Children: bookmarkEntries(node.Children),
+		})
+	}
+
+	return entries
+}
+
+// defaultBookmarkFolder returns the ID of the folder where the browser creates bookmarks by default.
+func defaultBookmarkFolder() (string, error) {
+	var tree []bookmarkNode
+	if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "bookmarks.getTree", []any{}, &tree); err != nil {
+		return "", fmt.Errorf("failed to get bookmarks: %w", err)
+	}
+
+	if len(tree) == 0 || len(tree[0].Children) == 0 {
+		return "", fmt.Errorf("no bookmark folder found")
+	}
+
+	for _, folder := range tree[0].Children {
+		// other bookmarks in chrome and firefox
+		if folder.ID == "2" || folder.ID == "unfiled_____" {
+			return folder.ID, nil
+		}
+	}
+
+	return tree[0].Children[len(tree[0].Children)-1].ID, nil
+}
+
+type bookmarkImporter struct {
+	socketPath string
+	created    int
+	skipped    int
+}
+
+func (i *bookmarkImporter) importEntries(parentId string, entries []bookmarkEntry) error {
+	var children []bookmarkNode
+	if err := jsonrpc.Call(i.socketPath, "bookmarks.getChildren", []any{parentId}, &children); err != nil {
+		return fmt.Errorf("failed to get bookmark folder %s: %w", parentId, err)
+	}
+
+	folders := make(map[string]string)
+	urls := make(map[string]bool)
+	for _, child := range children {
+		if child.URL != "" {
+			urls[child.URL] = true
+		} else if child.Type != "separator" {
+			folders[child.Title] = child.ID
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.URL != "" {
+			if urls[entry.URL] {
+				i.skipped++
+				continue
+			}
+
+			if err := jsonrpc.Call(i.socketPath, "bookmarks.create", []any{map[string]any{
+				"parentId": parentId,
+				"title":    entry.Title,
+				"url":      entry.URL,
+			}}, nil); err != nil {
+				return fmt.Errorf("failed to create bookmark %s: %w", entry.URL, err)
+			}
+
+			urls[entry.URL] = true
+			i.created++
+			continue
+		}
+
+		folderId, ok := folders[entry.Title]
+		if !ok {
+			var folder bookmarkNode
+			if err := jsonrpc.Call(i.socketPath, "bookmarks.create", []any{map[string]any{
+				"parentId": parentId,
+				"title":    entry.Title,
+			}}, &folder); err != nil {
+				return fmt.Errorf("failed to create folder %s: %w", entry.Title, err)
+			}
+
+			folderId = folder.ID
+			folders[entry.Title] = folderId
+		}
+
+		if err := i.importEntries(folderId, entry.Children); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeNetscapeBookmarks(w io.Writer, entries []bookmarkEntry) {
+	fmt.Fprintln(w, "<!DOCTYPE NETSCAPE-Bookmark-file-1>")
+	fmt.Fprintln(w, `<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">`)
+	fmt.Fprintln(w, "<TITLE>Bookmarks</TITLE>")
+	fmt.Fprintln(w, "<H1>Bookmarks</H1>")
+	writeNetscapeList(w, entries, 0)
+}
+
+func writeNetscapeList(w io.Writer, entries []bookmarkEntry, depth int) {
+	indent := strings.Repeat("    ", depth)
+	fmt.Fprintf(w, "%s<DL><p>\n", indent)
+	for _, entry := range entries {
+		if entry.URL != "" {
+			fmt.Fprintf(w, "%s    <DT><A HREF=\"%s\">%s</A>\n", indent, html.EscapeString(entry.URL), html.EscapeString(entry.Title))
+			continue
+		}
+
+		fmt.Fprintf(w, "%s    <DT><H3>%s</H3>\n", indent, html.EscapeString(entry.Title))
+		writeNetscapeList(w, entry.Children, depth+1)
+	}
+	fmt.Fprintf(w, "%s</DL><p>\n", indent)
+}
+
+func parseNetscapeBookmarks(r io.Reader) ([]bookmarkEntry, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// the list of a folder follows its heading
+	consumed := make(map[*html.Node]bool)
+	var walk func(n *html.Node) []bookmarkEntry
+	walk = func(n *html.Node) []bookmarkEntry {
+		var entries []bookmarkEntry
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+
+			switch c.DataAtom {
+			case atom.A:
+				entries = append(entries, bookmarkEntry{
+					Title: strings.TrimSpace(markdown.TextContent(c)),
+					URL:   markdown.Attr(c, "href"),
+				})
+			case atom.H3:
+				folder := bookmarkEntry{Title: strings.TrimSpace(markdown.TextContent(c))}
+				for s := c.NextSibling; s != nil; s = s.NextSibling {
+					if s.Type == html.ElementNode && s.DataAtom == atom.Dl {
+						consumed[s] = true
+						folder.Children = walk(s)
+						break
+					}
+				}
+				entries = append(entries, folder)
+			case atom.H1, atom.Title:
+				continue
+			default:
+				if !consumed[c] {
+					entries = append(entries, walk(c)...)
+				}
+			}
+		}
+
+		return entries
+	}
+
+	return walk(doc), nil
+}
+
+func writeMarkdownBookmarks(w io.Writer, entries []bookmarkEntry, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, entry := range entries {
+		if entry.URL != "" {
+			fmt.Fprintf(w, "%s- [%s](%s)\n", indent, markdownLinkText(entry.Title, entry.URL), strings.ReplaceAll(entry.URL, " ", "%20"))
+			continue
+		}
+
+		fmt.Fprintf(w, "%s- %s\n", indent, entry.Title)
+		writeMarkdownBookmarks(w, entry.Children, depth+1)
+	}
+}
+
+func markdownLinkText(title string, url string) string {
+	if title == "" {
+		title = url
+	}
+
+	return strings.NewReplacer("[", "\\[", "]", "\\]").Replace(title)
+}
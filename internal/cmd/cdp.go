@@ -0,0 +1,405 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cli/cli/v2/pkg/jsoncolor"
+	"github.com/gorilla/websocket"
+	"github.com/mattn/go-isatty"
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdCDP() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cdp <tabID> <Domain.method> [params]",
+		Short: "Send a devtools protocol command to a tab",
+		Long: `Send a devtools protocol command to a tab.
+
+Params are passed as a JSON object, use - to read them from stdin.
+The debugger is attached to the tab for the duration of the command, unless it was already attached.
+
+The tabs are also exposed to devtools protocol clients, at the websocket urls listed by tweety cdp targets.
+Only page targets are supported: clients must connect to the url of a tab, browser level clients
+such as puppeteer.connect or chromedp are not supported. chrome-remote-interface works with
+CDP({target: <webSocketDebuggerUrl>, local: true}).`,
+		Args: cobra.RangeArgs(2, 3),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if env := os.Getenv("TWEETY_SOCKET"); env == "" {
+				return fmt.Errorf("TWEETY_SOCKET environment variable must be set")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tabID, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid tab ID: %w", err)
+			}
+
+			params := json.RawMessage("{}")
+			if len(args) > 2 {
+				params = json.RawMessage(args[2])
+				if args[2] == "-" {
+					params, err = io.ReadAll(os.Stdin)
+					if err != nil {
+						return fmt.Errorf("failed to read params: %w", err)
+					}
+				}
+
+				if !json.Valid(params) {
+					return fmt.Errorf("params must be a valid JSON object")
+				}
+			}
+
+			socketPath := os.Getenv("TWEETY_SOCKET")
+			target := map[string]any{"tabId": tabID}
+
+			var attachResult struct {
+				AlreadyAttached bool `json:"alreadyAttached"`
+			}
+			if err := jsonrpc.Call(socketPath, "debugger.attach", []any{target, "1.3"}, &attachResult); err != nil {
+				return fmt.Errorf("failed to attach debugger: %w", err)
+			}
+
+			if !attachResult.AlreadyAttached {
+				defer jsonrpc.Call(socketPath, "debugger.detach", []any{target}, nil)
+			}
+
+			var result json.RawMessage
+			if err := jsonrpc.Call(socketPath, "debugger.sendCommand", []any{target, args[1], params}, &result); err != nil {
+				return fmt.Errorf("failed to send command: %w", err)
+			}
+
+			if len(result) == 0 {
+				result = json.RawMessage("{}")
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(result)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(result), "  ")
+			return nil
+		},
+	}
+
+	cmd.AddCommand(NewCmdCDPTargets())
+
+	return cmd
+}
+
+func NewCmdCDPTargets() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "targets",
+		Short: "List the tabs exposed by the devtools protocol endpoint",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var info struct {
+				DevtoolsURL string `json:"devtoolsUrl"`
+			}
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "host.getInfo", []any{}, &info); err != nil {
+				return fmt.Errorf("failed to get host info: %w", err)
+			}
+
+			resp, err := http.Get(info.DevtoolsURL + "/json/list")
+			if err != nil {
+				return fmt.Errorf("failed to list targets: %w", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return fmt.Errorf("failed to read targets: %w", err)
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("failed to list targets: %s", body)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(body)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(body), "  ")
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// CDPBridge exposes the tabs of the browser through a devtools protocol compatible endpoint,
+// relaying commands and events through the chrome.debugger api of the extension.
+// Only page targets are supported, clients must connect to the websocket url of a tab.
+// The endpoint is served under /devtools/<token>, the token is only given to the cli through host.getInfo.
+type CDPBridge struct {
+	logger *slog.Logger
+	host   *jsonrpc.Host
+	port   int
+	token  string
+
+	mu       sync.Mutex
+	sessions map[int]map[*cdpSession]bool
+}
+
+type cdpSession struct {
+	tabID   int
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	// events are queued, so that they are forwarded in order without blocking the messaging host
+	events chan any
+}
+
+func (s *cdpSession) writeJSON(v any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return s.conn.WriteJSON(v)
+}
+
+type cdpTarget struct {
+	TabID int `json:"tabId"`
+}
+
+func NewCDPBridge(logger *slog.Logger, host *jsonrpc.Host, port int) *CDPBridge {
+	bridge := &CDPBridge{
+		logger:   logger,
+		host:     host,
+		port:     port,
+		token:    rand.Text(),
+		sessions: make(map[int]map[*cdpSession]bool),
+	}
+
+	host.HandleNotification("debugger.onEvent", func(input []byte) error {
+		var params struct {
+			Source cdpTarget       `json:"source"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(input, &params); err != nil {
+			return fmt.Errorf("failed to unmarshal debugger event: %w", err)
+		}
+
+		for _, session := range bridge.tabSessions(params.Source.TabID) {
+			select {
+			case session.events <- map[string]any{"method": params.Method, "params": params.Params}:
+			default:
+				logger.Error("devtools client is too slow, dropping event", "tabId", session.tabID, "method", params.Method)
+			}
+		}
+
+		return nil
+	})
+
+	host.HandleNotification("debugger.onDetach", func(input []byte) error {
+		var params struct {
+			Source cdpTarget `json:"source"`
+			Reason string    `json:"reason"`
+		}
+		if err := json.Unmarshal(input, &params); err != nil {
+			return fmt.Errorf("failed to unmarshal debugger detach: %w", err)
+		}
+
+		for _, session := range bridge.tabSessions(params.Source.TabID) {
+			session.conn.Close()
+		}
+
+		return nil
+	})
+
+	return bridge
+}
+
+// URL returns the base url of the endpoint, which includes its token.
+func (b *CDPBridge) URL() string {
+	return fmt.Sprintf("http://127.0.0.1:%d/devtools/%s", b.port, b.token)
+}
+
+func (b *CDPBridge) tabSessions(tabID int) []*cdpSession {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var sessions []*cdpSession
+	for session := range b.sessions[tabID] {
+		sessions = append(sessions, session)
+	}
+
+	return sessions
+}
+
+func (b *CDPBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the endpoint gives full control over the browser, so it must not be reachable from web pages,
+	// either directly (origin header) or through dns rebinding (host header)
+	if r.Header.Get("Origin") != "" {
+		http.Error(w, "requests from web pages are not allowed", http.StatusForbidden)
+		return
+	}
+
+	if hostname, _, err := net.SplitHostPort(r.Host); err != nil || (hostname != "127.0.0.1" && hostname != "localhost") {
+		http.Error(w, "invalid host header", http.StatusForbidden)
+		return
+	}
+
+	token, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/devtools/"), "/")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(b.token)) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case path == "json/version":
+		b.handleVersion(w, r)
+	case path == "json" || path == "json/list":
+		b.handleList(w, r)
+	case strings.HasPrefix(path, "page/"):
+		b.handleSession(w, r, strings.TrimPrefix(path, "page/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleVersion has no webSocketDebuggerUrl, as there is no browser target.
+func (b *CDPBridge) handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"Browser":          "tweety",
+		"Protocol-Version": "1.3",
+	})
+}
+
+func (b *CDPBridge) handleList(w http.ResponseWriter, r *http.Request) {
+	var tabs []browserTab
+	if err := b.host.Call("tabs.query", []any{map[string]any{}}, &tabs); err != nil {
+		http.Error(w, fmt.Sprintf("failed to list tabs: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	targets := []map[string]any{}
+	for _, tab := range tabs {
+		targets = append(targets, map[string]any{
+			"id":                   strconv.Itoa(tab.ID),
+			"type":                 "page",
+			"title":                tab.Title,
+			"url":                  tab.URL,
+			"webSocketDebuggerUrl": fmt.Sprintf("ws://127.0.0.1:%d/devtools/%s/page/%d", b.port, b.token, tab.ID),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(targets)
+}
+
+func (b *CDPBridge) handleSession(w http.ResponseWriter, r *http.Request, page string) {
+	tabID, err := strconv.Atoi(page)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid tab ID: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	target := cdpTarget{TabID: tabID}
+	if err := b.host.Call("debugger.attach", []any{target, "1.3"}, nil); err != nil {
+		http.Error(w, fmt.Sprintf("failed to attach debugger: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		b.logger.Error("failed to upgrade connection", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	session := &cdpSession{tabID: tabID, conn: conn, events: make(chan any, 1024)}
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case event := <-session.events:
+				if err := session.writeJSON(event); err != nil {
+					b.logger.Error("failed to forward debugger event", "error", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	b.mu.Lock()
+	if b.sessions[tabID] == nil {
+		b.sessions[tabID] = make(map[*cdpSession]bool)
+	}
+	b.sessions[tabID][session] = true
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		delete(b.sessions[tabID], session)
+		last := len(b.sessions[tabID]) == 0
+		if last {
+			delete(b.sessions, tabID)
+		}
+		b.mu.Unlock()
+
+		if last {
+			if err := b.host.Call("debugger.detach", []any{target}, nil); err != nil {
+				b.logger.Error("failed to detach debugger", "tabId", tabID, "error", err)
+			}
+		}
+	}()
+
+	// commands are sent one at a time, as clients rely on them being run in order
+	for {
+		var command struct {
+			ID     int             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := conn.ReadJSON(&command); err != nil {
+			return
+		}
+
+		params := command.Params
+		if len(params) == 0 {
+			params = json.RawMessage("{}")
+		}
+
+		var result json.RawMessage
+		err := b.host.Call("debugger.sendCommand", []any{target, command.Method, params}, &result)
+
+		response := map[string]any{"id": command.ID}
+		var rpcErr *jsonrpc.JSONRPCError
+		switch {
+		case errors.As(err, &rpcErr):
+			response["error"] = map[string]any{"code": rpcErr.Code, "message": rpcErr.Message}
+		case err != nil:
+			response["error"] = map[string]any{"code": -32000, "message": err.Error()}
+		case len(result) == 0:
+			response["result"] = json.RawMessage("{}")
+		default:
+			response["result"] = result
+		}
+
+		if err := session.writeJSON(response); err != nil {
+			b.logger.Error("failed to write devtools response", "error", err)
+		}
+	}
+}
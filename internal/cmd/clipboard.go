@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdClipboard() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clipboard",
+		Short: "Read and write the clipboard of the browser",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if env := os.Getenv("TWEETY_SOCKET"); env == "" {
+				return fmt.Errorf("TWEETY_SOCKET environment variable must be set")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.AddCommand(
+		NewCmdClipboardRead(),
+		NewCmdClipboardWrite(),
+	)
+
+	return cmd
+}
+
+func NewCmdClipboardRead() *cobra.Command {
+	var flags struct {
+		MimeType string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "read",
+		Short: "Print the content of the clipboard",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res struct {
+				Data string `json:"data"`
+			}
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "clipboard.read", []any{map[string]any{
+				"mimeType": flags.MimeType,
+			}}, &res); err != nil {
+				return fmt.Errorf("failed to read clipboard: %w", err)
+			}
+
+			os.Stdout.WriteString(res.Data)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.MimeType, "mime", "text/plain", "Type of the data to read (text/plain, text/html)")
+
+	return cmd
+}
+
+func NewCmdClipboardWrite() *cobra.Command {
+	var flags struct {
+		MimeType string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "write [text]",
+		Short: "Write text to the clipboard, reading it from stdin if omitted",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var data string
+			if len(args) > 0 {
+				data = args[0]
+			} else {
+				content, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("failed to read stdin: %w", err)
+				}
+				data = string(content)
+			}
+
+			if !strings.HasPrefix(flags.MimeType, "text/") {
+				return fmt.Errorf("unsupported mime type: %s", flags.MimeType)
+			}
+
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "clipboard.write", []any{map[string]any{
+				"data":     data,
+				"mimeType": flags.MimeType,
+			}}, nil); err != nil {
+				return fmt.Errorf("failed to write clipboard: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.MimeType, "mime", "text/plain", "Type of the data to write (text/plain, text/html)")
+
+	return cmd
+}
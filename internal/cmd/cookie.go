@@ -0,0 +1,393 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cli/cli/v2/pkg/jsoncolor"
+	"github.com/mattn/go-isatty"
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/spf13/cobra"
+)
+
+// browserCookie mirrors the fields of chrome.cookies.Cookie.
+type browserCookie struct {
+	Name           string  `json:"name"`
+	Value          string  `json:"value"`
+	Domain         string  `json:"domain"`
+	HostOnly       bool    `json:"hostOnly"`
+	Path           string  `json:"path"`
+	Secure         bool    `json:"secure"`
+	HttpOnly       bool    `json:"httpOnly"`
+	SameSite       string  `json:"sameSite"`
+	Session        bool    `json:"session"`
+	ExpirationDate float64 `json:"expirationDate"`
+}
+
+func NewCmdCookies() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "cookie",
+		Aliases: []string{"cookies"},
+		Short:   "Manage browser cookies",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if env := os.Getenv("TWEETY_SOCKET"); env == "" {
+				return fmt.Errorf("TWEETY_SOCKET environment variable must be set")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.AddCommand(
+		NewCmdCookiesList(),
+		NewCmdCookiesGet(),
+		NewCmdCookiesSet(),
+		NewCmdCookiesRemove(),
+		NewCmdCookiesExport(),
+	)
+
+	return cmd
+}
+
+func NewCmdCookiesList() *cobra.Command {
+	var flags struct {
+		URL    string
+		Domain string
+		Name   string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List cookies",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			details := make(map[string]any)
+			if flags.URL != "" {
+				details["url"] = flags.URL
+			}
+
+			if flags.Domain != "" {
+				details["domain"] = flags.Domain
+			}
+
+			if flags.Name != "" {
+				details["name"] = flags.Name
+			}
+
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "cookies.getAll", []any{details})
+			if err != nil {
+				return fmt.Errorf("failed to list cookies: %w", err)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(resp.Result)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.URL, "url", "", "Only list cookies sent to this URL")
+	cmd.Flags().StringVar(&flags.Domain, "domain", "", "Only list cookies of this domain or its subdomains")
+	cmd.Flags().StringVar(&flags.Name, "name", "", "Only list cookies with this name")
+
+	return cmd
+}
+
+func NewCmdCookiesGet() *cobra.Command {
+	var flags struct {
+		URL string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "get <name>",
+		Short: "Get a cookie",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "cookies.get", []any{map[string]any{
+				"url":  flags.URL,
+				"name": args[0],
+			}})
+			if err != nil {
+				return fmt.Errorf("failed to get cookie: %w", err)
+			}
+
+			if resp.Error != nil {
+				os.Stderr.Write(resp.Error)
+				os.Exit(1)
+			}
+
+			if string(resp.Result) == "null" {
+				return fmt.Errorf("cookie not found: %s", args[0])
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(resp.Result)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.URL, "url", "", "URL the cookie is associated with")
+	cmd.MarkFlagRequired("url")
+
+	return cmd
+}
+
+func NewCmdCookiesSet() *cobra.Command {
+	var flags struct {
+		URL      string
+		Domain   string
+		Path     string
+		Secure   bool
+		HttpOnly bool
+		SameSite string
+		Expires  time.Duration
+	}
+
+	cmd := &cobra.Command{
+		Use:   "set <name> <value>",
+		Short: "Set a cookie",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			details := map[string]any{
+				"url":   flags.URL,
+				"name":  args[0],
+				"value": args[1],
+			}
+
+			if flags.Domain != "" {
+				details["domain"] = flags.Domain
+			}
+
+			if flags.Path != "" {
+				details["path"] = flags.Path
+			}
+
+			if cmd.Flags().Changed("secure") {
+				details["secure"] = flags.Secure
+			}
+
+			if cmd.Flags().Changed("http-only") {
+				details["httpOnly"] = flags.HttpOnly
+			}
+
+			if flags.SameSite != "" {
+				details["sameSite"] = flags.SameSite
+			}
+
+			// without an expiration date, the cookie only lasts for the session
+			if flags.Expires > 0 {
+				details["expirationDate"] = time.Now().Add(flags.Expires).Unix()
+			}
+
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "cookies.set", []any{details})
+			if err != nil {
+				return fmt.Errorf("failed to set cookie: %w", err)
+			}
+
+			if resp.Error != nil {
+				os.Stderr.Write(resp.Error)
+				os.Exit(1)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(resp.Result)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.URL, "url", "", "URL the cookie is associated with")
+	cmd.MarkFlagRequired("url")
+	cmd.Flags().StringVar(&flags.Domain, "domain", "", "Domain of the cookie, host-only if omitted")
+	cmd.Flags().StringVar(&flags.Path, "path", "", "Path of the cookie, defaults to the path of the URL")
+	cmd.Flags().BoolVar(&flags.Secure, "secure", false, "Only send the cookie over secure connections")
+	cmd.Flags().BoolVar(&flags.HttpOnly, "http-only", false, "Hide the cookie from client-side scripts")
+	cmd.Flags().StringVar(&flags.SameSite, "same-site", "", "SameSite status of the cookie (no_restriction, lax, strict)")
+	cmd.Flags().DurationVar(&flags.Expires, "expires", 0, "Lifetime of the cookie, session cookie if omitted")
+	cmd.RegisterFlagCompletionFunc("same-site", cobra.FixedCompletions([]string{"no_restriction", "lax", "strict"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func NewCmdCookiesRemove() *cobra.Command {
+	var flags struct {
+		URL string
+	}
+
+	cmd := &cobra.Command{
+		Use:     "remove <name>",
+		Aliases: []string{"rm", "delete"},
+		Short:   "Remove a cookie",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "cookies.remove", []any{map[string]any{
+				"url":  flags.URL,
+				"name": args[0],
+			}})
+			if err != nil {
+				return fmt.Errorf("failed to remove cookie: %w", err)
+			}
+
+			if resp.Error != nil {
+				os.Stderr.Write(resp.Error)
+				os.Exit(1)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.URL, "url", "", "URL the cookie is associated with")
+	cmd.MarkFlagRequired("url")
+
+	return cmd
+}
+
+func NewCmdCookiesExport() *cobra.Command {
+	var flags struct {
+		URL    string
+		Domain string
+		Format string
+		Output string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export cookies to a cookie jar",
+		Long: `Export cookies to a cookie jar.
+
+The netscape format can be loaded by curl (--cookie) and wget (--load-cookies).
+The json format can be decoded into a []*http.Cookie in go.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			details := make(map[string]any)
+			if flags.URL != "" {
+				details["url"] = flags.URL
+			}
+
+			if flags.Domain != "" {
+				details["domain"] = flags.Domain
+			}
+
+			var cookies []browserCookie
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "cookies.getAll", []any{details}, &cookies); err != nil {
+				return fmt.Errorf("failed to list cookies: %w", err)
+			}
+
+			var buf bytes.Buffer
+			switch flags.Format {
+			case "netscape":
+				writeNetscapeCookies(&buf, cookies)
+			case "json":
+				var httpCookies []*http.Cookie
+				for _, cookie := range cookies {
+					httpCookies = append(httpCookies, cookie.httpCookie())
+				}
+
+				encoder := json.NewEncoder(&buf)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(httpCookies); err != nil {
+					return fmt.Errorf("failed to encode cookies: %w", err)
+				}
+			default:
+				return fmt.Errorf("invalid format: %s", flags.Format)
+			}
+
+			if flags.Output == "" || flags.Output == "-" {
+				os.Stdout.Write(buf.Bytes())
+				return nil
+			}
+
+			// cookie jars hold credentials, so they are only readable by the current user
+			if err := os.WriteFile(flags.Output, buf.Bytes(), 0600); err != nil {
+				return fmt.Errorf("failed to write cookie jar: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.URL, "url", "", "Only export cookies sent to this URL")
+	cmd.Flags().StringVar(&flags.Domain, "domain", "", "Only export cookies of this domain or its subdomains")
+	cmd.Flags().StringVar(&flags.Format, "format", "netscape", "Format of the cookie jar (netscape, json)")
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "", "File to write the cookie jar to (- for stdout)")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"netscape", "json"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func writeNetscapeCookies(w io.Writer, cookies []browserCookie) {
+	fmt.Fprintln(w, "# Netscape HTTP Cookie File")
+	for _, cookie := range cookies {
+		domain := cookie.Domain
+		// curl and wget understand this prefix, and would otherwise skip the line as a comment
+		if cookie.HttpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+
+		var expires int64
+		if !cookie.Session {
+			expires = int64(cookie.ExpirationDate)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain,
+			netscapeBool(!cookie.HostOnly),
+			cookie.Path,
+			netscapeBool(cookie.Secure),
+			expires,
+			cookie.Name,
+			cookie.Value,
+		)
+	}
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+
+	return "FALSE"
+}
+
+func (c browserCookie) httpCookie() *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   strings.TrimPrefix(c.Domain, "."),
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+	}
+
+	if !c.Session {
+		cookie.Expires = time.Unix(int64(c.ExpirationDate), 0).UTC()
+	}
+
+	switch c.SameSite {
+	case "lax":
+		cookie.SameSite = http.SameSiteLaxMode
+	case "strict":
+		cookie.SameSite = http.SameSiteStrictMode
+	case "no_restriction":
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+
+	return cookie
+}
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cli/cli/v2/pkg/jsoncolor"
+	"github.com/mattn/go-isatty"
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/spf13/cobra"
+)
+
+// downloadWaitTimeout must stay below the timeout of the cli http client, the cli polls until the download is done
+var downloadWaitTimeout = 5 * time.Second
+
+type downloadItem struct {
+	ID       int    `json:"id"`
+	URL      string `json:"url"`
+	Filename string `json:"filename"`
+	State    string `json:"state"`
+	Error    string `json:"error"`
+}
+
+func NewCmdDownloads() *cobra.Command {
+	var flags struct {
+		Filename string
+		SaveAs   bool
+		Wait     bool
+	}
+
+	cmd := &cobra.Command{
+		Use:     "download <url>",
+		Aliases: []string{"downloads"},
+		Short:   "Download a file using the browser",
+		Long: `Download a file using the browser, reusing its cookies and credentials.
+
+The ID of the download is printed, or the path of the downloaded file when using --wait.`,
+		Args: cobra.ExactArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if env := os.Getenv("TWEETY_SOCKET"); env == "" {
+				return fmt.Errorf("TWEETY_SOCKET environment variable must be set")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options := map[string]any{
+				"url": args[0],
+			}
+
+			if flags.Filename != "" {
+				options["filename"] = flags.Filename
+			}
+
+			if cmd.Flags().Changed("save-as") {
+				options["saveAs"] = flags.SaveAs
+			}
+
+			var downloadID int
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "downloads.download", []any{options}, &downloadID); err != nil {
+				return fmt.Errorf("failed to start download: %w", err)
+			}
+
+			if !flags.Wait {
+				fmt.Println(downloadID)
+				return nil
+			}
+
+			for {
+				var item downloadItem
+				if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "downloads.wait", map[string]any{
+					"id":      downloadID,
+					"timeout": downloadWaitTimeout.Milliseconds(),
+				}, &item); err != nil {
+					return fmt.Errorf("failed to wait for download: %w", err)
+				}
+
+				switch item.State {
+				case "in_progress":
+					continue
+				case "complete":
+					fmt.Println(item.Filename)
+					return nil
+				default:
+					return fmt.Errorf("download %d was interrupted: %s", downloadID, item.Error)
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Filename, "filename", "", "Path of the file, relative to the downloads directory")
+	cmd.Flags().BoolVar(&flags.SaveAs, "save-as", false, "Prompt the user for the file location")
+	cmd.Flags().BoolVar(&flags.Wait, "wait", false, "Wait for the download to complete, and print the path of the file")
+
+	cmd.AddCommand(
+		NewCmdDownloadsList(),
+		NewCmdDownloadsAction("pause", "Pause a download", "downloads.pause"),
+		NewCmdDownloadsAction("resume", "Resume a paused download", "downloads.resume"),
+		NewCmdDownloadsAction("cancel", "Cancel a download", "downloads.cancel"),
+		NewCmdDownloadsAction("show", "Show a downloaded file in the file manager", "downloads.show"),
+		NewCmdDownloadsErase(),
+	)
+
+	return cmd
+}
+
+func NewCmdDownloadsList() *cobra.Command {
+	var flags struct {
+		Query string
+		State string
+		Limit int
+	}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List downloads, most recent first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := map[string]any{
+				"orderBy": []string{"-startTime"},
+			}
+
+			if flags.Query != "" {
+				query["query"] = []string{flags.Query}
+			}
+
+			if flags.State != "" {
+				query["state"] = flags.State
+			}
+
+			if flags.Limit > 0 {
+				query["limit"] = flags.Limit
+			}
+
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "downloads.search", []any{query})
+			if err != nil {
+				return fmt.Errorf("failed to list downloads: %w", err)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(resp.Result)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Query, "query", "q", "", "Only list downloads whose URL or filename contains this text")
+	cmd.Flags().StringVar(&flags.State, "state", "", "Only list downloads in this state (in_progress, interrupted, complete)")
+	cmd.Flags().IntVar(&flags.Limit, "limit", 0, "Maximum number of downloads to list")
+	cmd.RegisterFlagCompletionFunc("state", cobra.FixedCompletions([]string{"in_progress", "interrupted", "complete"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+// NewCmdDownloadsAction creates a command calling a downloads method taking a single download ID.
+func NewCmdDownloadsAction(name string, short string, method string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("%s <downloadID>", name),
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			downloadID, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid download ID: %w", err)
+			}
+
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), method, []any{downloadID}, nil); err != nil {
+				return fmt.Errorf("failed to %s download: %w", name, err)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func NewCmdDownloadsErase() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "erase <downloadID> [<downloadID>...]",
+		Short: "Erase downloads from the history, without deleting the files",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, arg := range args {
+				downloadID, err := strconv.Atoi(arg)
+				if err != nil {
+					return fmt.Errorf("invalid download ID '%s': %w", arg, err)
+				}
+
+				if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "downloads.erase", []any{map[string]any{"id": downloadID}}, nil); err != nil {
+					return fmt.Errorf("failed to erase download %d: %w", downloadID, err)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// handleDownloadsWait blocks until the download leaves the in_progress state, or the timeout expires,
+// and returns the download item.
+func handleDownloadsWait(host *jsonrpc.Host) jsonrpc.RequestHandlerFunc {
+	return func(input []byte) (any, error) {
+		var params struct {
+			ID      int `json:"id"`
+			Timeout int `json:"timeout"`
+		}
+
+		if err := json.Unmarshal(input, &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal downloads.wait params: %w", err)
+		}
+
+		// subscribe before checking the state, so that no change is missed
+		events, unsubscribe := host.Subscribe("downloads.onChanged")
+		defer unsubscribe()
+
+		timeout := time.After(time.Duration(params.Timeout) * time.Millisecond)
+		for {
+			var items []json.RawMessage
+			if err := host.Call("downloads.search", []any{map[string]any{"id": params.ID}}, &items); err != nil {
+				return nil, fmt.Errorf("failed to get download: %w", err)
+			}
+
+			if len(items) == 0 {
+				return nil, fmt.Errorf("download not found: %d", params.ID)
+			}
+
+			var item downloadItem
+			if err := json.Unmarshal(items[0], &item); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal download: %w", err)
+			}
+
+			if item.State != "in_progress" {
+				return items[0], nil
+			}
+
+		wait:
+			for {
+				select {
+				case event := <-events:
+					var delta struct {
+						ID int `json:"id"`
+					}
+					if err := json.Unmarshal(event, &delta); err == nil && delta.ID == params.ID {
+						break wait
+					}
+				case <-timeout:
+					return items[0], nil
+				}
+			}
+		}
+	}
+}
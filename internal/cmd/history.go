@@ -2,8 +2,15 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"html"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cli/cli/v2/pkg/jsoncolor"
 	"github.com/mattn/go-isatty"
@@ -11,6 +18,16 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// historyItem mirrors the fields of chrome.history.HistoryItem, times are in milliseconds since the epoch.
+type historyItem struct {
+	ID            string  `json:"id"`
+	URL           string  `json:"url"`
+	Title         string  `json:"title"`
+	LastVisitTime float64 `json:"lastVisitTime"`
+	VisitCount    int     `json:"visitCount"`
+	TypedCount    int     `json:"typedCount"`
+}
+
 func NewCmdHistory() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "history",
@@ -26,16 +43,78 @@ func NewCmdHistory() *cobra.Command {
 
 	cmd.AddCommand(
 		NewCmdHistorySearch(),
+		NewCmdHistoryVisits(),
 		NewCmdHistoryAdd(),
 		NewCmdHistoryRemove(),
+		NewCmdHistoryDeleteRange(),
+		NewCmdHistoryDeleteAll(),
+		NewCmdHistoryExport(),
 	)
 
 	return cmd
 }
 
+// parseHistoryTime accepts a duration relative to now (2h, 7d), a date (2006-01-02) or a RFC3339 timestamp.
+func parseHistoryTime(value string) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time '%s': expected a duration (2h, 7d), a date (2006-01-02) or a RFC3339 timestamp", value)
+}
+
+// historyQuery builds the query of history.search, an empty text matches every page.
+// Without since, the browser searches the last 24 hours, since can be "all" to search the whole history.
+func historyQuery(text string, since string, until string, maxResults int) (map[string]any, error) {
+	query := map[string]any{
+		"text": text,
+	}
+
+	if since == "all" {
+		query["startTime"] = 0
+	} else if since != "" {
+		t, err := parseHistoryTime(since)
+		if err != nil {
+			return nil, err
+		}
+		query["startTime"] = t.UnixMilli()
+	}
+
+	if until != "" {
+		t, err := parseHistoryTime(until)
+		if err != nil {
+			return nil, err
+		}
+		query["endTime"] = t.UnixMilli()
+	}
+
+	if maxResults > 0 {
+		query["maxResults"] = maxResults
+	}
+
+	return query, nil
+}
+
 func NewCmdHistorySearch() *cobra.Command {
 	var flags struct {
-		Text string `json:"text"`
+		Text       string
+		Since      string
+		Until      string
+		MaxResults int
 	}
 
 	cmd := &cobra.Command{
@@ -43,8 +122,9 @@ func NewCmdHistorySearch() *cobra.Command {
 		Short: "Search browser history",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			query := map[string]any{
-				"text": flags.Text,
+			query, err := historyQuery(flags.Text, flags.Since, flags.Until, flags.MaxResults)
+			if err != nil {
+				return err
 			}
 
 			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "history.search", []any{
@@ -64,8 +144,41 @@ func NewCmdHistorySearch() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&flags.Text, "text", "t", "", "Text to search in history")
-	cmd.MarkFlagRequired("text")
+	cmd.Flags().StringVarP(&flags.Text, "text", "t", "", "Text to search in history, all pages match if omitted")
+	cmd.Flags().StringVar(&flags.Since, "since", "", "Only include pages visited after this time (2h, 7d, 2006-01-02, all), defaults to the last 24 hours")
+	cmd.Flags().StringVar(&flags.Until, "until", "", "Only include pages visited before this time (2h, 7d, 2006-01-02)")
+	cmd.Flags().IntVar(&flags.MaxResults, "max-results", 0, "Maximum number of results, defaults to 100")
+
+	return cmd
+}
+
+func NewCmdHistoryVisits() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "visits <url>",
+		Short: "List the visits to a URL",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "history.getVisits", []any{map[string]any{
+				"url": args[0],
+			}})
+			if err != nil {
+				return fmt.Errorf("failed to get visits: %w", err)
+			}
+
+			if resp.Error != nil {
+				os.Stderr.Write(resp.Error)
+				os.Exit(1)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(resp.Result)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+			return nil
+		},
+	}
 
 	return cmd
 }
@@ -74,28 +187,27 @@ func NewCmdHistoryAdd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "add",
 		Short: "Add entry to browser history",
+		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			url, _ := cmd.Flags().GetString("url")
 			title, _ := cmd.Flags().GetString("title")
 
-			params := map[string]interface{}{"url": url, "title": title}
-			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "history.add", params)
-			if err != nil {
-				return fmt.Errorf("failed to add history entry: %w", err)
+			details := map[string]any{"url": url}
+			// only supported by firefox, chrome uses the title of the page
+			if title != "" {
+				details["title"] = title
 			}
 
-			if !isatty.IsTerminal(os.Stdout.Fd()) {
-				os.Stdout.Write(resp.Result)
-				return nil
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "history.addUrl", []any{details}, nil); err != nil {
+				return fmt.Errorf("failed to add history entry: %w", err)
 			}
 
-			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
 			return nil
 		},
 	}
 
 	cmd.Flags().StringP("url", "u", "", "URL to add to history")
-	cmd.Flags().StringP("title", "t", "", "Title for the history entry")
+	cmd.Flags().StringP("title", "t", "", "Title for the history entry (firefox only)")
 	cmd.MarkFlagRequired("url")
 
 	return cmd
@@ -104,13 +216,12 @@ func NewCmdHistoryAdd() *cobra.Command {
 func NewCmdHistoryRemove() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "remove",
-		Short: "Remove entry from browser history",
+		Short: "Remove all visits to a URL from browser history",
+		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			url, _ := cmd.Flags().GetString("url")
 
-			params := map[string]interface{}{"url": url}
-			_, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "history.remove", params)
-			if err != nil {
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "history.deleteUrl", []any{map[string]any{"url": url}}, nil); err != nil {
 				return fmt.Errorf("failed to remove history entry: %w", err)
 			}
 
@@ -123,3 +234,197 @@ func NewCmdHistoryRemove() *cobra.Command {
 
 	return cmd
 }
+
+func NewCmdHistoryDeleteRange() *cobra.Command {
+	var flags struct {
+		Since string
+		Until string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "delete-range",
+		Short: "Remove the pages visited in a time range from browser history",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			since, err := parseHistoryTime(flags.Since)
+			if err != nil {
+				return err
+			}
+
+			until := time.Now()
+			if flags.Until != "" {
+				until, err = parseHistoryTime(flags.Until)
+				if err != nil {
+					return err
+				}
+			}
+
+			if !since.Before(until) {
+				return fmt.Errorf("--since must be before --until")
+			}
+
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "history.deleteRange", []any{map[string]any{
+				"startTime": since.UnixMilli(),
+				"endTime":   until.UnixMilli(),
+			}}, nil); err != nil {
+				return fmt.Errorf("failed to delete history range: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Since, "since", "", "Start of the range (2h, 7d, 2006-01-02)")
+	cmd.Flags().StringVar(&flags.Until, "until", "", "End of the range, defaults to now (2h, 7d, 2006-01-02)")
+	cmd.MarkFlagRequired("since")
+
+	return cmd
+}
+
+func NewCmdHistoryDeleteAll() *cobra.Command {
+	var flags struct {
+		Confirm bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "delete-all",
+		Short: "Remove all pages from browser history",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !flags.Confirm {
+				return fmt.Errorf("this deletes the whole browser history, use --confirm to proceed")
+			}
+
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "history.deleteAll", []any{}, nil); err != nil {
+				return fmt.Errorf("failed to delete history: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.Confirm, "confirm", false, "Confirm the deletion")
+
+	return cmd
+}
+
+func NewCmdHistoryExport() *cobra.Command {
+	var flags struct {
+		Format     string
+		Output     string
+		Text       string
+		Since      string
+		Until      string
+		MaxResults int
+	}
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export browser history",
+		Long: `Export browser history.
+
+Pages are exported with their last visit time and visit count.
+The csv and jsonl formats are meant for analysis, the html format can be opened in a browser.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// the whole history is exported by default
+			since := flags.Since
+			if since == "" {
+				since = "all"
+			}
+
+			query, err := historyQuery(flags.Text, since, flags.Until, flags.MaxResults)
+			if err != nil {
+				return err
+			}
+
+			var items []historyItem
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "history.search", []any{query}, &items); err != nil {
+				return fmt.Errorf("failed to search history: %w", err)
+			}
+
+			var buf bytes.Buffer
+			switch flags.Format {
+			case "csv":
+				if err := writeHistoryCSV(&buf, items); err != nil {
+					return fmt.Errorf("failed to encode history: %w", err)
+				}
+			case "jsonl":
+				encoder := json.NewEncoder(&buf)
+				for _, item := range items {
+					if err := encoder.Encode(item); err != nil {
+						return fmt.Errorf("failed to encode history: %w", err)
+					}
+				}
+			case "html":
+				writeHistoryHTML(&buf, items)
+			default:
+				return fmt.Errorf("invalid format: %s", flags.Format)
+			}
+
+			if flags.Output == "" || flags.Output == "-" {
+				os.Stdout.Write(buf.Bytes())
+				return nil
+			}
+
+			if err := os.WriteFile(flags.Output, buf.Bytes(), 0600); err != nil {
+				return fmt.Errorf("failed to write history: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Format, "format", "jsonl", "Format of the export (csv, jsonl, html)")
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "", "File to write the export to (- for stdout)")
+	cmd.Flags().StringVarP(&flags.Text, "text", "t", "", "Only export pages matching this text")
+	cmd.Flags().StringVar(&flags.Since, "since", "", "Only export pages visited after this time (2h, 7d, 2006-01-02)")
+	cmd.Flags().StringVar(&flags.Until, "until", "", "Only export pages visited before this time (2h, 7d, 2006-01-02)")
+	cmd.Flags().IntVar(&flags.MaxResults, "max-results", 100000, "Maximum number of pages to export")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"csv", "jsonl", "html"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func (item historyItem) lastVisit() time.Time {
+	return time.UnixMilli(int64(item.LastVisitTime))
+}
+
+func writeHistoryCSV(w io.Writer, items []historyItem) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"url", "title", "last_visit_time", "visit_count", "typed_count"})
+	for _, item := range items {
+		writer.Write([]string{
+			item.URL,
+			item.Title,
+			item.lastVisit().Format(time.RFC3339),
+			strconv.Itoa(item.VisitCount),
+			strconv.Itoa(item.TypedCount),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeHistoryHTML(w io.Writer, items []historyItem) {
+	fmt.Fprintln(w, "<!DOCTYPE html>")
+	fmt.Fprintln(w, `<meta charset="utf-8">`)
+	fmt.Fprintln(w, "<title>History</title>")
+	fmt.Fprintln(w, "<table>")
+	fmt.Fprintln(w, "<tr><th>Last Visit</th><th>Page</th><th>Visits</th></tr>")
+	for _, item := range items {
+		title := item.Title
+		if title == "" {
+			title = item.URL
+		}
+
+		fmt.Fprintf(w, "<tr><td>%s</td><td><a href=\"%s\">%s</a></td><td>%d</td></tr>\n",
+			item.lastVisit().Format("2006-01-02 15:04"),
+			html.EscapeString(item.URL),
+			html.EscapeString(title),
+			item.VisitCount,
+		)
+	}
+	fmt.Fprintln(w, "</table>")
+}
@@ -2,8 +2,13 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/cli/cli/v2/pkg/jsoncolor"
 	"github.com/mattn/go-isatty"
@@ -11,6 +16,9 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// notificationWaitTimeout must stay below the timeout of the cli http client, the cli polls until the notification is dismissed
+var notificationWaitTimeout = 5 * time.Second
+
 func NewCmdNotifications() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "notification",
@@ -26,50 +34,430 @@ func NewCmdNotifications() *cobra.Command {
 
 	cmd.AddCommand(
 		NewCmdNotificationsCreate(),
+		NewCmdNotificationsUpdate(),
+		NewCmdNotificationsClear(),
+		NewCmdNotificationsList(),
 	)
 
 	return cmd
 }
 
-func NewCmdNotificationsCreate() *cobra.Command {
-	var options struct {
-		Type    string `json:"type"`
-		Title   string `json:"title"`
-		Message string `json:"message"`
-		IconURL string `json:"iconUrl"`
+type notificationFlags struct {
+	Type               string
+	Title              string
+	Message            string
+	IconURL            string
+	Buttons            []string
+	Items              []string
+	Progress           int
+	RequireInteraction bool
+}
+
+func (f *notificationFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.Type, "type", "", "Type of notification (basic, image, list, progress), guessed from the other flags if omitted")
+	cmd.Flags().StringVar(&f.Title, "title", "", "Title of the notification")
+	cmd.Flags().StringVar(&f.Message, "message", "", "Message of the notification")
+	cmd.Flags().StringVar(&f.IconURL, "icon-url", "/icon/128.png", "URL of the icon for the notification")
+	cmd.Flags().StringArrayVar(&f.Buttons, "button", nil, "Add a button to the notification, can be repeated up to two times")
+	cmd.Flags().StringArrayVar(&f.Items, "item", nil, "Add an item to a list notification, formatted as title:message")
+	cmd.Flags().IntVar(&f.Progress, "progress", 0, "Progress of a progress notification, between 0 and 100")
+	cmd.Flags().BoolVar(&f.RequireInteraction, "require-interaction", false, "Keep the notification visible until the user interacts with it")
+	cmd.RegisterFlagCompletionFunc("type", cobra.FixedCompletions([]string{"basic", "image", "list", "progress"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// options returns the notification options matching the flags, only including the flags that were set,
+// except for the type and the icon of created notifications.
+func (f *notificationFlags) options(cmd *cobra.Command, create bool) (map[string]any, error) {
+	options := make(map[string]any)
+
+	switch {
+	case cmd.Flags().Changed("type"):
+		options["type"] = f.Type
+	case len(f.Items) > 0:
+		options["type"] = "list"
+	case cmd.Flags().Changed("progress"):
+		options["type"] = "progress"
+	case create:
+		options["type"] = "basic"
+	}
+
+	if create || cmd.Flags().Changed("title") {
+		options["title"] = f.Title
 	}
+
+	if create || cmd.Flags().Changed("message") {
+		options["message"] = f.Message
+	}
+
+	if create || cmd.Flags().Changed("icon-url") {
+		options["iconUrl"] = f.IconURL
+	}
+
+	if len(f.Buttons) > 2 {
+		return nil, fmt.Errorf("notifications can have at most two buttons")
+	}
+
+	if len(f.Buttons) > 0 {
+		var buttons []map[string]any
+		for _, button := range f.Buttons {
+			buttons = append(buttons, map[string]any{"title": button})
+		}
+		options["buttons"] = buttons
+	}
+
+	if len(f.Items) > 0 {
+		var items []map[string]any
+		for _, item := range f.Items {
+			title, message, ok := strings.Cut(item, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid item '%s': expected title:message", item)
+			}
+			items = append(items, map[string]any{"title": title, "message": message})
+		}
+		options["items"] = items
+	}
+
+	if cmd.Flags().Changed("progress") {
+		if f.Progress < 0 || f.Progress > 100 {
+			return nil, fmt.Errorf("progress must be between 0 and 100")
+		}
+		options["progress"] = f.Progress
+	}
+
+	if cmd.Flags().Changed("require-interaction") {
+		options["requireInteraction"] = f.RequireInteraction
+	}
+
+	return options, nil
+}
+
+func NewCmdNotificationsCreate() *cobra.Command {
+	var flags notificationFlags
+	var wait bool
+
 	cmd := &cobra.Command{
 		Use:   "create [notification-id]",
 		Short: "Create a new browser notification",
-		Args:  cobra.MaximumNArgs(1),
+		Long: `Create a new browser notification.
+
+With --wait, the command blocks until the user interacts with the notification, and prints
+"clicked", "closed", or the title of the pressed button. It exits with status 1 if the
+notification was closed without clicking it.`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			options, err := flags.options(cmd, true)
+			if err != nil {
+				return err
+			}
+
+			// otherwise the notification is moved to the notification center after a few seconds
+			if wait && !cmd.Flags().Changed("require-interaction") {
+				options["requireInteraction"] = true
+			}
+
 			var requestArgs []any
 			if len(args) > 0 {
 				requestArgs = append(requestArgs, args[0])
 			}
 
 			requestArgs = append(requestArgs, options)
-			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "notifications.create", requestArgs)
-			if err != nil {
+			if !wait {
+				resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "notifications.create", requestArgs)
+				if err != nil {
+					return fmt.Errorf("failed to create notification: %w", err)
+				}
+
+				if !isatty.IsTerminal(os.Stdout.Fd()) {
+					os.Stdout.Write(resp.Result)
+					return nil
+				}
+
+				jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+				return nil
+			}
+
+			// drop the outcome of a previous notification with the same ID
+			if len(args) > 0 {
+				if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "notifications.wait", map[string]any{"id": args[0], "timeout": 0}, nil); err != nil {
+					return fmt.Errorf("failed to reset notification: %w", err)
+				}
+			}
+
+			var notificationID string
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "notifications.create", requestArgs, &notificationID); err != nil {
 				return fmt.Errorf("failed to create notification: %w", err)
 			}
 
-			if !isatty.IsTerminal(os.Stdout.Fd()) {
-				os.Stdout.Write(resp.Result)
-				return nil
+			event, err := waitNotification(notificationID)
+			if err != nil {
+				return err
+			}
+
+			switch event.Action {
+			case "clicked":
+				fmt.Println("clicked")
+			case "button":
+				if event.ButtonIndex < len(flags.Buttons) {
+					fmt.Println(flags.Buttons[event.ButtonIndex])
+				}
+			default:
+				fmt.Println("closed")
+				os.Exit(1)
 			}
 
-			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&options.Type, "type", "basic", "Type of notification (basic, image, list, progress)")
-	cmd.Flags().StringVar(&options.Title, "title", "", "Title of the notification")
+	flags.register(cmd)
 	cmd.MarkFlagRequired("title")
-	cmd.Flags().StringVar(&options.Message, "message", "", "Message of the notification")
 	cmd.MarkFlagRequired("message")
-	cmd.Flags().StringVar(&options.IconURL, "icon-url", "/icon/128.png", "URL of the icon for the notification")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the notification to be clicked or closed, and print the outcome")
 
 	return cmd
 }
+
+// waitNotification blocks until the notification is clicked or closed, and clears it.
+func waitNotification(notificationID string) (notificationEvent, error) {
+	for {
+		var event notificationEvent
+		if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "notifications.wait", map[string]any{
+			"id":      notificationID,
+			"timeout": notificationWaitTimeout.Milliseconds(),
+		}, &event); err != nil {
+			return notificationEvent{}, fmt.Errorf("failed to wait for notification: %w", err)
+		}
+
+		if event.Action == "" {
+			continue
+		}
+
+		// clicking a notification does not close it
+		if event.Action != "closed" {
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "notifications.clear", []any{notificationID}, nil); err != nil {
+				return notificationEvent{}, fmt.Errorf("failed to clear notification: %w", err)
+			}
+		}
+
+		return event, nil
+	}
+}
+
+func NewCmdNotificationsUpdate() *cobra.Command {
+	var flags notificationFlags
+
+	cmd := &cobra.Command{
+		Use:   "update <notification-id>",
+		Short: "Update an existing notification",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options, err := flags.options(cmd, false)
+			if err != nil {
+				return err
+			}
+
+			if len(options) == 0 {
+				return fmt.Errorf("at least one field must be provided to update")
+			}
+
+			var updated bool
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "notifications.update", []any{args[0], options}, &updated); err != nil {
+				return fmt.Errorf("failed to update notification: %w", err)
+			}
+
+			if !updated {
+				return fmt.Errorf("notification not found: %s", args[0])
+			}
+
+			return nil
+		},
+	}
+
+	flags.register(cmd)
+
+	return cmd
+}
+
+func NewCmdNotificationsClear() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clear <notification-id>",
+		Short: "Clear a notification",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var cleared bool
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "notifications.clear", []any{args[0]}, &cleared); err != nil {
+				return fmt.Errorf("failed to clear notification: %w", err)
+			}
+
+			if !cleared {
+				return fmt.Errorf("notification not found: %s", args[0])
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func NewCmdNotificationsList() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the IDs of the visible notifications",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var notifications map[string]any
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "notifications.getAll", []any{}, &notifications); err != nil {
+				return fmt.Errorf("failed to list notifications: %w", err)
+			}
+
+			for id := range notifications {
+				fmt.Println(id)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// maxNotificationEvents bounds the number of events kept for notifications nobody waits for
+const maxNotificationEvents = 256
+
+type notificationEvent struct {
+	// Action is clicked, button or closed, it is empty if the notification is still visible
+	Action      string `json:"action,omitempty"`
+	ButtonIndex int    `json:"buttonIndex,omitempty"`
+	ByUser      bool   `json:"byUser,omitempty"`
+
+	// consumed is set once the event is returned by notifications.wait, until the notification is closed
+	consumed bool
+}
+
+// notificationTracker records the first interaction with each notification, so that the cli does not miss
+// an interaction happening before it starts waiting, or between two polls.
+type notificationTracker struct {
+	mu     sync.Mutex
+	events map[string]notificationEvent
+	order  []string
+	// changed is closed and replaced whenever an event is recorded
+	changed chan struct{}
+}
+
+func newNotificationTracker(logger *slog.Logger, host *jsonrpc.Host) *notificationTracker {
+	tracker := &notificationTracker{
+		events:  make(map[string]notificationEvent),
+		changed: make(chan struct{}),
+	}
+
+	host.HandleNotification("notifications.onClicked", func(input []byte) error {
+		var params struct {
+			NotificationID string `json:"notificationId"`
+		}
+		if err := json.Unmarshal(input, &params); err != nil {
+			return fmt.Errorf("failed to unmarshal notification click: %w", err)
+		}
+
+		tracker.record(params.NotificationID, notificationEvent{Action: "clicked"})
+
+		// handlers must not block, and the host waits for the response of the extension
+		go func() {
+			if err := focusNotificationTab(host, params.NotificationID); err != nil {
+				logger.Error("failed to focus notification tab", "error", err)
+			}
+		}()
+
+		return nil
+	})
+
+	host.HandleNotification("notifications.onButtonClicked", func(input []byte) error {
+		var params struct {
+			NotificationID string `json:"notificationId"`
+			ButtonIndex    int    `json:"buttonIndex"`
+		}
+		if err := json.Unmarshal(input, &params); err != nil {
+			return fmt.Errorf("failed to unmarshal notification button click: %w", err)
+		}
+
+		tracker.record(params.NotificationID, notificationEvent{Action: "button", ButtonIndex: params.ButtonIndex})
+		return nil
+	})
+
+	host.HandleNotification("notifications.onClosed", func(input []byte) error {
+		var params struct {
+			NotificationID string `json:"notificationId"`
+			ByUser         bool   `json:"byUser"`
+		}
+		if err := json.Unmarshal(input, &params); err != nil {
+			return fmt.Errorf("failed to unmarshal notification close: %w", err)
+		}
+
+		tracker.record(params.NotificationID, notificationEvent{Action: "closed", ByUser: params.ByUser})
+		return nil
+	})
+
+	return tracker
+}
+
+func (t *notificationTracker) record(notificationID string, event notificationEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// the notification is closed once the cli clears it after a click, which ends the consumed click
+	if existing, ok := t.events[notificationID]; ok {
+		if existing.consumed && event.Action == "closed" {
+			delete(t.events, notificationID)
+		}
+		return
+	}
+
+	t.events[notificationID] = event
+	t.order = append(t.order, notificationID)
+	if len(t.order) > maxNotificationEvents {
+		delete(t.events, t.order[0])
+		t.order = t.order[1:]
+	}
+
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// handleWait blocks until the notification is clicked or closed, or the timeout expires,
+// and returns the notification event, which is then only returned once.
+func (t *notificationTracker) handleWait(input []byte) (any, error) {
+	var params struct {
+		ID      string `json:"id"`
+		Timeout int    `json:"timeout"`
+	}
+
+	if err := json.Unmarshal(input, &params); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal notifications.wait params: %w", err)
+	}
+
+	timeout := time.After(time.Duration(params.Timeout) * time.Millisecond)
+	for {
+		t.mu.Lock()
+		event, ok := t.events[params.ID]
+		changed := t.changed
+		t.mu.Unlock()
+
+		if ok && !event.consumed {
+			// a click is kept as consumed, so that the close event following it is not recorded
+			t.mu.Lock()
+			if event.Action == "closed" {
+				delete(t.events, params.ID)
+			} else {
+				t.events[params.ID] = notificationEvent{Action: event.Action, consumed: true}
+			}
+			t.mu.Unlock()
+			return event, nil
+		}
+
+		select {
+		case <-changed:
+		case <-timeout:
+			return notificationEvent{}, nil
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/spf13/cobra"
+)
+
+// notifyNotificationPrefix marks the notifications of terminal tabs, their ID is <prefix><tabID>:<random>.
+// Clicking them focuses the tab.
+const notifyNotificationPrefix = "tweety-tab:"
+
+// notifyOutputLines is the number of lines of output shown in the notification
+const notifyOutputLines = 5
+
+func NewCmdNotify() *cobra.Command {
+	var flags struct {
+		Title string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "notify [--title <title>] -- <command> [args...]",
+		Short: "Run a command, and send a notification when it completes",
+		Long: `Run a command, and send a notification with its exit code, its duration and the tail of its
+output when it completes. Clicking the notification focuses the terminal tab the command was run from.
+
+The command runs on the terminal, its output is read from the scrollback of the tweety terminal it runs in,
+it is not included in the notification outside of tweety terminals.
+
+The command exits with the exit code of the wrapped command.`,
+		Args: cobra.MinimumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if env := os.Getenv("TWEETY_SOCKET"); env == "" {
+				return fmt.Errorf("TWEETY_SOCKET environment variable must be set")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// the output of the command is the output of the tty written after this offset
+			ttyID := os.Getenv("TWEETY_TTY")
+			var tty ttyInfo
+			if ttyID != "" {
+				if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tty.get", map[string]any{"id": ttyID}, &tty); err != nil {
+					ttyID = ""
+				}
+			}
+
+			command := exec.Command(args[0], args[1:]...)
+			command.Stdin = os.Stdin
+			command.Stdout = os.Stdout
+			command.Stderr = os.Stderr
+
+			// signals sent to the foreground process group, such as ctrl-c, are handled by the command,
+			// the notification is sent once it exits. Caught signals are reset for the command, unlike ignored ones.
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
+			defer signal.Stop(signals)
+
+			start := time.Now()
+			err := command.Run()
+			duration := time.Since(start).Round(time.Second)
+
+			var exitErr *exec.ExitError
+			if err != nil && !errors.As(err, &exitErr) {
+				return err
+			}
+
+			// a command killed by a signal exits with 128 + the signal number, as in shells
+			exitCode := command.ProcessState.ExitCode()
+			if status, ok := command.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				exitCode = 128 + int(status.Signal())
+			}
+
+			title := flags.Title
+			if title == "" {
+				title = strings.Join(args, " ")
+			}
+
+			message := fmt.Sprintf("Completed in %s", duration)
+			if exitCode != 0 {
+				title = fmt.Sprintf("%s (exit code %d)", title, exitCode)
+				message = fmt.Sprintf("Failed after %s", duration)
+			}
+
+			if ttyID != "" {
+				var capture struct {
+					Output string `json:"output"`
+				}
+				if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tty.capture", map[string]any{"id": ttyID, "since": tty.Offset}, &capture); err == nil {
+					if tail := lastLines(capture.Output, notifyOutputLines); tail != "" {
+						message += "\n" + tail
+					}
+				}
+			}
+
+			if err := sendTerminalNotification(title, message); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to send notification: %s\n", err)
+			}
+
+			os.Exit(exitCode)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Title, "title", "", "Title of the notification, defaults to the command line")
+	cmd.Flags().SetInterspersed(false)
+
+	return cmd
+}
+
+// sendTerminalNotification sends a notification, which focuses the terminal tab of the current tty when clicked.
+func sendTerminalNotification(title string, message string) error {
+	options := map[string]any{
+		"type":    "basic",
+		"title":   title,
+		"message": message,
+		"iconUrl": "/icon/128.png",
+	}
+
+	params := []any{options}
+	if ttyID := os.Getenv("TWEETY_TTY"); ttyID != "" {
+		var tty struct {
+			TabID int `json:"tabId"`
+		}
+		if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tty.get", map[string]any{"id": ttyID}, &tty); err == nil && tty.TabID != 0 {
+			params = []any{terminalNotificationID(tty.TabID), options}
+		}
+	}
+
+	return jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "notifications.create", params, nil)
+}
+
+// focusNotificationTab focuses the terminal tab of a notification sent by sendTerminalNotification, and clears it.
+func focusNotificationTab(host *jsonrpc.Host, notificationID string) error {
+	rest, ok := strings.CutPrefix(notificationID, notifyNotificationPrefix)
+	if !ok {
+		return nil
+	}
+
+	tabIDStr, _, _ := strings.Cut(rest, ":")
+	tabID, err := strconv.Atoi(tabIDStr)
+	if err != nil {
+		return fmt.Errorf("invalid tab ID in notification %s: %w", notificationID, err)
+	}
+
+	var tab browserTab
+	if err := host.Call("tabs.update", []any{tabID, map[string]any{"active": true}}, &tab); err != nil {
+		return fmt.Errorf("failed to activate tab: %w", err)
+	}
+
+	if err := host.Call("windows.update", []any{tab.WindowID, map[string]any{"focused": true}}, nil); err != nil {
+		return fmt.Errorf("failed to focus window: %w", err)
+	}
+
+	return host.Call("notifications.clear", []any{notificationID}, nil)
+}
+
+// lastLines returns the last n lines of a text, without the surrounding blank lines.
+func lastLines(text string, n int) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,743 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cli/cli/v2/pkg/jsoncolor"
+	"github.com/mattn/go-isatty"
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/spf13/cobra"
+)
+
+// paneLayout is the tree of panes shown by a terminal page. Its ID is stored in the url of the page,
+// so that a reloaded page finds its layout.
+type paneLayout struct {
+	ID    string      `json:"id"`
+	Root  *layoutNode `json:"root"`
+	Focus string      `json:"focus"`
+
+	// idle is the time since which none of the panes has a tty, the layout is deleted after layoutExpiry
+	idle time.Time
+}
+
+// layoutExpiry is the time a layout is kept once none of its panes has a tty, which happens when its page is closed.
+// It is longer than ttyDetachTimeout, so that a reloaded page finds its layout.
+const layoutExpiry = time.Minute
+
+// connected reports whether a pane of the layout has a tty, and thus a page showing it.
+// It must be called with the registry locked.
+func (r *ttyRegistry) connected(layout *paneLayout) bool {
+	for _, pane := range layout.Root.panes() {
+		if _, ok := r.sessions[pane.TTY]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expireLayouts schedules the deletion of the layouts which no longer have a tty.
+// It must be called with the registry locked.
+func (r *ttyRegistry) expireLayouts() {
+	for _, layout := range r.layouts {
+		if !layout.idle.IsZero() || r.connected(layout) {
+			continue
+		}
+
+		layout.idle = time.Now()
+		time.AfterFunc(layoutExpiry, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			if !layout.idle.IsZero() && time.Since(layout.idle) >= layoutExpiry && !r.connected(layout) {
+				delete(r.layouts, layout.ID)
+			}
+		})
+	}
+}
+
+// layoutNode is either a pane running a tty, or a split of its children.
+type layoutNode struct {
+	Pane string `json:"pane,omitempty"`
+	TTY  string `json:"tty,omitempty"`
+	Cwd  string `json:"cwd,omitempty"`
+
+	// Direction is horizontal for children side by side, and vertical for stacked children
+	Direction string `json:"direction,omitempty"`
+	// Sizes are the percentages of the split taken by each child
+	Sizes    []float64     `json:"sizes,omitempty"`
+	Children []*layoutNode `json:"children,omitempty"`
+
+	// spec is the program of the pane, run again when the page is reloaded
+	spec *ttySpec
+}
+
+func newPaneID() string {
+	return strings.ToLower(rand.Text())[:8]
+}
+
+func (n *layoutNode) find(paneID string) *layoutNode {
+	if n == nil {
+		return nil
+	}
+
+	if n.Pane != "" {
+		if n.Pane == paneID {
+			return n
+		}
+		return nil
+	}
+
+	for _, child := range n.Children {
+		if node := child.find(paneID); node != nil {
+			return node
+		}
+	}
+
+	return nil
+}
+
+// parent returns the split containing a pane, and the index of the pane in the split.
+func (n *layoutNode) parent(paneID string) (*layoutNode, int) {
+	if n == nil {
+		return nil, -1
+	}
+
+	for i, child := range n.Children {
+		if child.Pane == paneID {
+			return n, i
+		}
+
+		if parent, index := child.parent(paneID); parent != nil {
+			return parent, index
+		}
+	}
+
+	return nil, -1
+}
+
+// panes returns the panes of the tree, from left to right and top to bottom.
+func (n *layoutNode) panes() []*layoutNode {
+	if n == nil {
+		return nil
+	}
+
+	if n.Pane != "" {
+		return []*layoutNode{n}
+	}
+
+	var panes []*layoutNode
+	for _, child := range n.Children {
+		panes = append(panes, child.panes()...)
+	}
+
+	return panes
+}
+
+// split adds a pane next to an existing one, taking size percent of its space.
+func (l *paneLayout) split(paneID string, direction string, size float64, pane *layoutNode) error {
+	node := l.Root.find(paneID)
+	if node == nil {
+		return fmt.Errorf("pane not found: %s", paneID)
+	}
+
+	if parent, index := l.Root.parent(paneID); parent != nil && parent.Direction == direction {
+		total := parent.Sizes[index]
+		parent.Sizes[index] = total * (100 - size) / 100
+		parent.Children = slices.Insert(parent.Children, index+1, pane)
+		parent.Sizes = slices.Insert(parent.Sizes, index+1, total*size/100)
+		return nil
+	}
+
+	// the pane is replaced by a split of itself and the new pane
+	existing := *node
+	*node = layoutNode{
+		Direction: direction,
+		Sizes:     []float64{100 - size, size},
+		Children:  []*layoutNode{&existing, pane},
+	}
+
+	return nil
+}
+
+// remove removes a pane, giving its space to its previous sibling, or to the next one for the first child.
+func (l *paneLayout) remove(paneID string) {
+	if l.Root == nil || l.Root.Pane == paneID {
+		l.Root = nil
+		l.Focus = ""
+		return
+	}
+
+	parent, index := l.Root.parent(paneID)
+	if parent == nil {
+		return
+	}
+
+	size := parent.Sizes[index]
+	parent.Children = slices.Delete(parent.Children, index, index+1)
+	parent.Sizes = slices.Delete(parent.Sizes, index, index+1)
+
+	neighbor := max(index-1, 0)
+	parent.Sizes[neighbor] += size
+
+	focus := parent.Children[neighbor]
+	if len(parent.Children) == 1 {
+		*parent = *focus
+		focus = parent
+	}
+
+	if l.Focus == paneID {
+		l.Focus = focus.panes()[0].Pane
+	}
+}
+
+// copyLayoutNode returns a copy of a tree, with the current working directory of the running panes.
+// It must be called with the registry locked.
+func (r *ttyRegistry) copyLayoutNode(node *layoutNode) *layoutNode {
+	if node == nil {
+		return nil
+	}
+
+	copied := &layoutNode{
+		Pane:      node.Pane,
+		TTY:       node.TTY,
+		Cwd:       node.Cwd,
+		Direction: node.Direction,
+		Sizes:     slices.Clone(node.Sizes),
+	}
+
+	if session, ok := r.sessions[node.TTY]; ok {
+		if cwd := session.info().Cwd; cwd != "" {
+			copied.Cwd = cwd
+		}
+	}
+
+	for _, child := range node.Children {
+		copied.Children = append(copied.Children, r.copyLayoutNode(child))
+	}
+
+	return copied
+}
+
+// snapshot returns a copy of a layout, which can be encoded once the registry is unlocked.
+// It must be called with the registry locked.
+func (r *ttyRegistry) snapshot(layout *paneLayout) *paneLayout {
+	return &paneLayout{
+		ID:    layout.ID,
+		Root:  r.copyLayoutNode(layout.Root),
+		Focus: layout.Focus,
+	}
+}
+
+// layoutParams identify a layout, either by its ID for terminal pages, or by one of its ttys for the cli.
+// Pane defaults to the pane of the tty, then to the focused pane.
+type layoutParams struct {
+	ID   string `json:"id"`
+	TTY  string `json:"tty"`
+	Pane string `json:"pane"`
+}
+
+// resolve returns the layout and the pane targeted by the params, the pane is nil if the layout has no panes.
+// It must be called with the registry locked.
+func (r *ttyRegistry) resolve(params layoutParams) (*paneLayout, *layoutNode, error) {
+	layoutID, paneID := params.ID, params.Pane
+	if layoutID == "" {
+		if params.TTY == "" {
+			return nil, nil, fmt.Errorf("either a layout or a tty is required")
+		}
+
+		session, ok := r.sessions[params.TTY]
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid tty ID: %s", params.TTY)
+		}
+
+		if session.LayoutID == "" {
+			return nil, nil, fmt.Errorf("tty %s is not a pane of a terminal tab", params.TTY)
+		}
+
+		layoutID = session.LayoutID
+		if paneID == "" {
+			paneID = session.PaneID
+		}
+	}
+
+	layout, ok := r.layouts[layoutID]
+	if !ok {
+		return nil, nil, fmt.Errorf("layout not found: %s", layoutID)
+	}
+
+	if paneID == "" {
+		paneID = layout.Focus
+	}
+
+	if paneID == "" {
+		return layout, nil, nil
+	}
+
+	pane := layout.Root.find(paneID)
+	if pane == nil {
+		return nil, nil, fmt.Errorf("pane not found: %s", paneID)
+	}
+
+	return layout, pane, nil
+}
+
+// paneSpec returns the program to run in a pane: the one it ran before the page was reloaded,
+// or the one requested by the page, started in the last working directory of the pane for shells.
+// The tty of the pane is also returned if it is still running, for the page to attach to it again.
+func (r *ttyRegistry) paneSpec(layoutID string, paneID string, spec ttySpec) (ttySpec, *ttySession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	layout, pane, err := r.resolve(layoutParams{ID: layoutID, Pane: paneID})
+	if err != nil {
+		return ttySpec{}, nil, err
+	}
+
+	if pane == nil {
+		return ttySpec{}, nil, fmt.Errorf("layout %s has no panes", layout.ID)
+	}
+
+	if pane.spec != nil {
+		spec = *pane.spec
+	}
+
+	if spec.Mode == "" && pane.Cwd != "" {
+		spec.Cwd = pane.Cwd
+	}
+
+	if session, ok := r.sessions[pane.TTY]; ok && !session.hasExited() {
+		return spec, session, nil
+	}
+
+	return spec, nil, nil
+}
+
+// setPaneTTY records the tty running in a pane, and its program.
+func (r *ttyRegistry) setPaneTTY(layoutID string, paneID string, ttyID string, spec ttySpec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	layout, ok := r.layouts[layoutID]
+	if !ok {
+		return
+	}
+
+	if pane := layout.Root.find(paneID); pane != nil {
+		pane.TTY = ttyID
+		pane.spec = &spec
+		layout.idle = time.Time{}
+	}
+}
+
+// registerPaneHandlers registers the methods managing the layouts of the terminal pages.
+// Changes are sent to the pages as tty.layoutChanged notifications, so that they render the new layout.
+func registerPaneHandlers(logger *slog.Logger, host *jsonrpc.Host, ttys *ttyRegistry) {
+	notifyLayout := func(layout *paneLayout) {
+		if err := host.SendNotification("tty.layoutChanged", layout); err != nil {
+			logger.Error("failed to send layout", "layout", layout.ID, "error", err)
+		}
+	}
+
+	handleLayout := func(input []byte) (any, error) {
+		var params struct {
+			layoutParams
+			// Create creates a layout with a single pane if it doesn't exist, it is set by the terminal pages
+			Create bool `json:"create"`
+		}
+
+		if err := json.Unmarshal(input, &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tty.layout params: %w", err)
+		}
+
+		ttys.mu.Lock()
+		defer ttys.mu.Unlock()
+
+		if _, ok := ttys.layouts[params.ID]; params.Create && !ok {
+			paneID := newPaneID()
+			layout := &paneLayout{
+				ID:    strings.ToLower(rand.Text()),
+				Root:  &layoutNode{Pane: paneID},
+				Focus: paneID,
+			}
+
+			ttys.layouts[layout.ID] = layout
+			// the layout is deleted if its page never starts a tty
+			ttys.expireLayouts()
+			return ttys.snapshot(layout), nil
+		}
+
+		layout, _, err := ttys.resolve(params.layoutParams)
+		if err != nil {
+			return nil, err
+		}
+
+		return ttys.snapshot(layout), nil
+	}
+	host.HandleRequest("tty.layout", handleLayout)
+	host.HandleLocalRequest("tty.layout", handleLayout)
+
+	handleSplit := func(input []byte) (any, error) {
+		var params struct {
+			layoutParams
+			Direction string  `json:"direction"`
+			Size      float64 `json:"size"`
+		}
+
+		if err := json.Unmarshal(input, &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tty.split params: %w", err)
+		}
+
+		if params.Direction == "" {
+			params.Direction = "horizontal"
+		}
+
+		if params.Direction != "horizontal" && params.Direction != "vertical" {
+			return nil, fmt.Errorf("invalid direction: %s, expected horizontal or vertical", params.Direction)
+		}
+
+		if params.Size == 0 {
+			params.Size = 50
+		}
+
+		if params.Size <= 0 || params.Size >= 100 {
+			return nil, fmt.Errorf("invalid size: %v, expected a percentage between 0 and 100", params.Size)
+		}
+
+		ttys.mu.Lock()
+		layout, pane, err := ttys.resolve(params.layoutParams)
+		if err != nil {
+			ttys.mu.Unlock()
+			return nil, err
+		}
+
+		if pane == nil {
+			ttys.mu.Unlock()
+			return nil, fmt.Errorf("layout %s has no panes", layout.ID)
+		}
+
+		// the new pane starts in the working directory of the pane it splits
+		newPane := &layoutNode{Pane: newPaneID(), Cwd: pane.Cwd}
+		if session, ok := ttys.sessions[pane.TTY]; ok {
+			if cwd := session.info().Cwd; cwd != "" {
+				newPane.Cwd = cwd
+			}
+		}
+
+		if err := layout.split(pane.Pane, params.Direction, params.Size, newPane); err != nil {
+			ttys.mu.Unlock()
+			return nil, err
+		}
+		layout.Focus = newPane.Pane
+
+		snapshot := ttys.snapshot(layout)
+		ttys.mu.Unlock()
+
+		notifyLayout(snapshot)
+		return map[string]string{
+			"id":   layout.ID,
+			"pane": newPane.Pane,
+		}, nil
+	}
+	host.HandleRequest("tty.split", handleSplit)
+	host.HandleLocalRequest("tty.split", handleSplit)
+
+	handleFocus := func(input []byte) (any, error) {
+		var params layoutParams
+		if err := json.Unmarshal(input, &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tty.focus params: %w", err)
+		}
+
+		ttys.mu.Lock()
+		layout, pane, err := ttys.resolve(params)
+		if err != nil {
+			ttys.mu.Unlock()
+			return nil, err
+		}
+
+		if pane == nil {
+			ttys.mu.Unlock()
+			return nil, fmt.Errorf("layout %s has no panes", layout.ID)
+		}
+
+		// pages report the focus of their panes, they are only notified when it changes
+		changed := layout.Focus != pane.Pane
+		layout.Focus = pane.Pane
+		snapshot := ttys.snapshot(layout)
+		ttys.mu.Unlock()
+
+		if changed {
+			notifyLayout(snapshot)
+		}
+
+		return snapshot, nil
+	}
+	host.HandleRequest("tty.focus", handleFocus)
+	host.HandleLocalRequest("tty.focus", handleFocus)
+
+	// tty.closePane is sent by the pages when the program of a pane exits
+	host.HandleRequest("tty.closePane", func(input []byte) (any, error) {
+		var params layoutParams
+		if err := json.Unmarshal(input, &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tty.closePane params: %w", err)
+		}
+
+		if params.Pane == "" {
+			return nil, fmt.Errorf("pane is required")
+		}
+
+		ttys.mu.Lock()
+		layout, pane, err := ttys.resolve(params)
+		if err != nil {
+			ttys.mu.Unlock()
+			return nil, err
+		}
+
+		layout.remove(pane.Pane)
+		if layout.Root == nil {
+			delete(ttys.layouts, layout.ID)
+		}
+
+		snapshot := ttys.snapshot(layout)
+		ttys.mu.Unlock()
+
+		notifyLayout(snapshot)
+		return snapshot, nil
+	})
+}
+
+func NewCmdPane() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pane",
+		Short: "Manage the panes of the current terminal tab",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if env := os.Getenv("TWEETY_SOCKET"); env == "" {
+				return fmt.Errorf("TWEETY_SOCKET environment variable must be set")
+			}
+
+			if env := os.Getenv("TWEETY_TTY"); env == "" {
+				return fmt.Errorf("TWEETY_TTY environment variable must be set, pane commands must be run from a terminal tab")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.AddCommand(
+		NewCmdPaneSplit(),
+		NewCmdPaneList(),
+		NewCmdPaneFocus(),
+		NewCmdPaneSendKeys(),
+	)
+
+	return cmd
+}
+
+func NewCmdPaneSplit() *cobra.Command {
+	var flags struct {
+		Direction string
+		Size      float64
+		Pane      string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "split",
+		Short: "Split a pane, and print the ID of the new pane",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res struct {
+				Pane string `json:"pane"`
+			}
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tty.split", map[string]any{
+				"tty":       os.Getenv("TWEETY_TTY"),
+				"pane":      flags.Pane,
+				"direction": flags.Direction,
+				"size":      flags.Size,
+			}, &res); err != nil {
+				return fmt.Errorf("failed to split pane: %w", err)
+			}
+
+			fmt.Println(res.Pane)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Direction, "direction", "d", "horizontal", "Direction of the split, horizontal puts the new pane on the right, vertical below")
+	cmd.Flags().Float64Var(&flags.Size, "size", 50, "Percentage of the space of the pane taken by the new pane")
+	cmd.Flags().StringVarP(&flags.Pane, "pane", "t", "", "ID of the pane to split, defaults to the current pane")
+	cmd.RegisterFlagCompletionFunc("direction", cobra.FixedCompletions([]string{"horizontal", "vertical"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func NewCmdPaneList() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the panes of the current terminal tab",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var layout paneLayout
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tty.layout", map[string]any{
+				"tty": os.Getenv("TWEETY_TTY"),
+			}, &layout); err != nil {
+				return fmt.Errorf("failed to get layout: %w", err)
+			}
+
+			type paneInfo struct {
+				ID      string `json:"id"`
+				TTY     string `json:"tty,omitempty"`
+				Cwd     string `json:"cwd,omitempty"`
+				Focused bool   `json:"focused"`
+				Current bool   `json:"current"`
+			}
+
+			panes := make([]paneInfo, 0)
+			for _, pane := range layout.Root.panes() {
+				panes = append(panes, paneInfo{
+					ID:      pane.Pane,
+					TTY:     pane.TTY,
+					Cwd:     pane.Cwd,
+					Focused: pane.Pane == layout.Focus,
+					Current: pane.TTY == os.Getenv("TWEETY_TTY"),
+				})
+			}
+
+			output, err := json.Marshal(panes)
+			if err != nil {
+				return fmt.Errorf("failed to marshal panes: %w", err)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(output)
+				return nil
+			}
+
+			return jsoncolor.Write(os.Stdout, bytes.NewReader(output), "  ")
+		},
+	}
+
+	return cmd
+}
+
+func NewCmdPaneFocus() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "focus <pane>",
+		Short: "Focus a pane of the current terminal tab",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tty.focus", map[string]any{
+				"tty":  os.Getenv("TWEETY_TTY"),
+				"pane": args[0],
+			}, nil); err != nil {
+				return fmt.Errorf("failed to focus pane: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func NewCmdPaneSendKeys() *cobra.Command {
+	var flags struct {
+		Pane string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "send-keys [--pane <pane>] <keys>...",
+		Short: "Send keys to a pane",
+		Long: `Send keys to the program running in a pane, as if they were typed in the terminal.
+
+Each argument is either a key name (Enter, Tab, Escape, Space, BSpace, Up, Down, Left, Right, C-<key> for control keys),
+or text which is sent as is. Use -- to send text starting with a dash.`,
+		Example: `  tweety pane send-keys --pane 1a2b3c4d 'make test' Enter`,
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var layout paneLayout
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tty.layout", map[string]any{
+				"tty": os.Getenv("TWEETY_TTY"),
+			}, &layout); err != nil {
+				return fmt.Errorf("failed to get layout: %w", err)
+			}
+
+			ttyID := os.Getenv("TWEETY_TTY")
+			if flags.Pane != "" {
+				pane := layout.Root.find(flags.Pane)
+				if pane == nil {
+					return fmt.Errorf("pane not found: %s", flags.Pane)
+				}
+
+				if pane.TTY == "" {
+					return fmt.Errorf("pane %s is not running", flags.Pane)
+				}
+
+				ttyID = pane.TTY
+			}
+
+			var data strings.Builder
+			for _, arg := range args {
+				data.WriteString(keyInput(arg))
+			}
+
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tty.write", map[string]any{
+				"id":   ttyID,
+				"data": data.String(),
+			}, nil); err != nil {
+				return fmt.Errorf("failed to send keys: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Pane, "pane", "t", "", "ID of the pane, defaults to the current pane")
+
+	return cmd
+}
+
+// keyInput returns the input sent by the terminal for a key name, other arguments are sent as is.
+func keyInput(key string) string {
+	switch key {
+	case "Enter":
+		return "\r"
+	case "Tab":
+		return "\t"
+	case "Escape":
+		return "\x1b"
+	case "Space":
+		return " "
+	case "BSpace":
+		return "\x7f"
+	case "Up":
+		return "\x1b[A"
+	case "Down":
+		return "\x1b[B"
+	case "Right":
+		return "\x1b[C"
+	case "Left":
+		return "\x1b[D"
+	}
+
+	// C-a to C-z, and C-[ C-\ C-] C-^ C-_ map to the control characters
+	if rest, ok := strings.CutPrefix(key, "C-"); ok && len(rest) == 1 {
+		c := rest[0]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+
+		if c >= '@' && c <= '_' {
+			return string(rune(c - '@'))
+		}
+	}
+
+	return key
+}
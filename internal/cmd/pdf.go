@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/spf13/cobra"
+)
+
+// pdfTimeout is the time the browser has to print a tab, long pages take several seconds to print
+const pdfTimeout = 2 * time.Minute
+
+// paperSizes maps paper names to their width and height, in inches
+var paperSizes = map[string][2]float64{
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
+	"a3":      {11.69, 16.54},
+	"a4":      {8.27, 11.69},
+	"a5":      {5.83, 8.27},
+}
+
+func NewCmdTabsPDF() *cobra.Command {
+	var flags struct {
+		Output     string
+		Paper      string
+		Margin     string
+		Landscape  bool
+		Background bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "pdf [tabID]",
+		Short: "Print a tab to PDF",
+		Long: `Print a tab to PDF.
+
+Chromium browsers print the page through the debugger protocol, and the PDF is written to the output file.
+Firefox does not expose the PDF content, so the file is saved by the browser itself, in its downloads directory:
+the output must be a file name without a directory, printing to stdout is not supported.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.Output == "" && isatty.IsTerminal(os.Stdout.Fd()) {
+				return fmt.Errorf("refusing to write a PDF to a terminal, use --output to write it to a file")
+			}
+
+			paper, ok := paperSizes[strings.ToLower(flags.Paper)]
+			if !ok {
+				return fmt.Errorf("invalid paper size: %s", flags.Paper)
+			}
+
+			margin, err := parseLength(flags.Margin)
+			if err != nil {
+				return fmt.Errorf("invalid margin: %w", err)
+			}
+
+			var tabID any
+			if len(args) > 0 {
+				id, err := strconv.Atoi(args[0])
+				if err != nil {
+					return fmt.Errorf("invalid tab ID: %w", err)
+				}
+				tabID = id
+			}
+
+			// options follow the Page.printToPDF parameters of the devtools protocol
+			options := map[string]any{
+				"landscape":       flags.Landscape,
+				"printBackground": flags.Background,
+				"paperWidth":      paper[0],
+				"paperHeight":     paper[1],
+				"marginTop":       margin,
+				"marginBottom":    margin,
+				"marginLeft":      margin,
+				"marginRight":     margin,
+			}
+
+			// browsers which can't return the PDF content only print tabs saved to the downloads directory
+			if flags.Output != "" && flags.Output != "-" && filepath.Base(flags.Output) == flags.Output {
+				options["fileName"] = flags.Output
+			}
+
+			var res struct {
+				Data   string `json:"data"`
+				Status string `json:"status"`
+			}
+			// the host gives up after pdfTimeout, the request is kept open a bit longer to receive its error
+			if err := jsonrpc.CallTimeout(os.Getenv("TWEETY_SOCKET"), "tabs.printToPDF", []any{tabID, options}, &res, pdfTimeout+5*time.Second); err != nil {
+				return fmt.Errorf("failed to print tab to PDF: %w", err)
+			}
+
+			if res.Data == "" {
+				if res.Status != "saved" && res.Status != "replaced" {
+					return fmt.Errorf("failed to print tab to PDF: %s", res.Status)
+				}
+
+				cmd.PrintErrln("PDF saved by the browser in its downloads directory.")
+				return nil
+			}
+
+			pdfBytes, err := base64.StdEncoding.DecodeString(res.Data)
+			if err != nil {
+				return fmt.Errorf("failed to decode PDF: %w", err)
+			}
+
+			if flags.Output == "" || flags.Output == "-" {
+				os.Stdout.Write(pdfBytes)
+				return nil
+			}
+
+			if err := os.WriteFile(flags.Output, pdfBytes, 0644); err != nil {
+				return fmt.Errorf("failed to write PDF: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "", "File to write the PDF to (- for stdout)")
+	cmd.Flags().StringVar(&flags.Paper, "paper", "letter", "Paper size (letter, legal, tabloid, a3, a4, a5)")
+	cmd.Flags().StringVar(&flags.Margin, "margin", "0.4in", "Page margins (in, cm or mm)")
+	cmd.Flags().BoolVar(&flags.Landscape, "landscape", false, "Use landscape orientation")
+	cmd.Flags().BoolVar(&flags.Background, "background", false, "Print background colors and images")
+	cmd.RegisterFlagCompletionFunc("paper", cobra.FixedCompletions([]string{"letter", "legal", "tabloid", "a3", "a4", "a5"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+// parseLength converts a length such as 1cm, 10mm or 0.5in to inches. Values without a unit are in inches.
+func parseLength(s string) (float64, error) {
+	units := []struct {
+		suffix string
+		inches float64
+	}{
+		{"in", 1},
+		{"cm", 1 / 2.54},
+		{"mm", 1 / 25.4},
+	}
+
+	factor := 1.0
+	for _, unit := range units {
+		if value, ok := strings.CutSuffix(s, unit.suffix); ok {
+			s = value
+			factor = unit.inches
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if value < 0 {
+		return 0, fmt.Errorf("length must be positive")
+	}
+
+	return value * factor, nil
+}
@@ -0,0 +1,453 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cli/cli/v2/pkg/jsoncolor"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+var recordingDir = filepath.Join(dataDir, "recordings")
+
+// castHeader is the first line of an asciicast v2 file, see https://docs.asciinema.org/manual/asciicast/v2/
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// recorder writes the output and the resizes of a tty to an asciicast v2 file.
+// The header is written with the first event, once the terminal page has sent the size of the terminal.
+type recorder struct {
+	mu     sync.Mutex
+	file   *os.File
+	header castHeader
+	start  time.Time
+	// started is set once the header is written
+	started bool
+	// pending holds the end of an utf-8 sequence split between two reads
+	pending []byte
+}
+
+func newRecorder(ttyID string, header castHeader) (*recorder, error) {
+	if err := os.MkdirAll(recordingDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create recordings directory: %w", err)
+	}
+
+	start := time.Now()
+	name := fmt.Sprintf("%s-%s.cast", start.Format("2006-01-02T15-04-05"), ttyID[:8])
+	// recordings may contain secrets typed in the terminal
+	file, err := os.OpenFile(filepath.Join(recordingDir, name), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	header.Version = 2
+	header.Timestamp = start.Unix()
+	if header.Width == 0 || header.Height == 0 {
+		header.Width, header.Height = 80, 24
+	}
+
+	return &recorder{file: file, header: header, start: start}, nil
+}
+
+// writeEvent writes an event, it must be called with the recorder locked.
+func (r *recorder) writeEvent(code string, data string) error {
+	if !r.started {
+		r.started = true
+		header, err := json.Marshal(r.header)
+		if err != nil {
+			return err
+		}
+
+		if _, err := r.file.Write(append(header, '\n')); err != nil {
+			return err
+		}
+	}
+
+	event, err := json.Marshal([]any{time.Since(r.start).Seconds(), code, data})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.file.Write(append(event, '\n'))
+	return err
+}
+
+// Write records output of the tty.
+func (r *recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data := append(r.pending, p...)
+	r.pending = nil
+
+	// events are json strings, an incomplete utf-8 sequence is kept for the next write
+	for i := len(data) - 1; i >= max(len(data)-utf8.UTFMax, 0); i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				r.pending = bytes.Clone(data[i:])
+				data = data[:i]
+			}
+			break
+		}
+	}
+
+	if len(data) == 0 {
+		return len(p), nil
+	}
+
+	if err := r.writeEvent("o", string(data)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// resize records a resize of the terminal, the size sent before the first output is used in the header.
+func (r *recorder) resize(cols int, rows int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.started {
+		r.header.Width, r.header.Height = cols, rows
+		return nil
+	}
+
+	return r.writeEvent("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+func (r *recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+// castEvent is an event of an asciicast v2 file: [time, code, data].
+type castEvent struct {
+	Time float64
+	Code string
+	Data string
+}
+
+func (e *castEvent) UnmarshalJSON(data []byte) error {
+	var fields []any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	if len(fields) != 3 {
+		return fmt.Errorf("invalid event: %s", data)
+	}
+
+	var ok bool
+	if e.Time, ok = fields[0].(float64); !ok {
+		return fmt.Errorf("invalid event time: %s", data)
+	}
+
+	e.Code, _ = fields[1].(string)
+	e.Data, _ = fields[2].(string)
+	return nil
+}
+
+// readCast reads the header of a recording, and calls fn for each of its events.
+func readCast(path string, fn func(event castEvent) error) (castHeader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return castHeader{}, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var header castHeader
+	if !scanner.Scan() {
+		// a recording without output has no header yet
+		return header, scanner.Err()
+	}
+
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return header, fmt.Errorf("invalid recording header: %w", err)
+	}
+
+	for scanner.Scan() {
+		var event castEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return header, fmt.Errorf("invalid recording event: %w", err)
+		}
+
+		if fn == nil {
+			continue
+		}
+
+		if err := fn(event); err != nil {
+			return header, err
+		}
+	}
+
+	return header, scanner.Err()
+}
+
+// recordingPath resolves the name of a recording, paths are used as is.
+func recordingPath(name string) (string, error) {
+	if strings.ContainsRune(name, os.PathSeparator) {
+		return name, nil
+	}
+
+	path := filepath.Join(recordingDir, name)
+	if !strings.HasSuffix(path, ".cast") {
+		path += ".cast"
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("recording not found: %s", name)
+	}
+
+	return path, nil
+}
+
+func NewCmdRec() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rec",
+		Short: "Manage the recordings of terminal sessions",
+		Long: `Manage the recordings of terminal sessions, stored in the asciicast v2 format.
+
+Terminals are recorded when the record setting is enabled, or when they are opened with tweety term --record.`,
+	}
+
+	cmd.AddCommand(
+		NewCmdRecList(),
+		NewCmdRecPlay(),
+		NewCmdRecExport(),
+	)
+
+	return cmd
+}
+
+func NewCmdRecList() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the recordings",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := os.ReadDir(recordingDir)
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to read recordings directory: %w", err)
+			}
+
+			type recordingInfo struct {
+				Name      string    `json:"name"`
+				Path      string    `json:"path"`
+				Title     string    `json:"title,omitempty"`
+				Timestamp time.Time `json:"timestamp"`
+				Duration  float64   `json:"duration"`
+				Size      int64     `json:"size"`
+			}
+
+			recordings := make([]recordingInfo, 0)
+			for _, entry := range entries {
+				if entry.IsDir() || filepath.Ext(entry.Name()) != ".cast" {
+					continue
+				}
+
+				info, err := entry.Info()
+				if err != nil {
+					continue
+				}
+
+				path := filepath.Join(recordingDir, entry.Name())
+				var duration float64
+				header, err := readCast(path, func(event castEvent) error {
+					duration = event.Time
+					return nil
+				})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "skipping %s: %s\n", entry.Name(), err)
+					continue
+				}
+
+				timestamp := info.ModTime()
+				if header.Timestamp != 0 {
+					timestamp = time.Unix(header.Timestamp, 0)
+				}
+
+				recordings = append(recordings, recordingInfo{
+					Name:      strings.TrimSuffix(entry.Name(), ".cast"),
+					Path:      path,
+					Title:     header.Title,
+					Timestamp: timestamp,
+					Duration:  duration,
+					Size:      info.Size(),
+				})
+			}
+
+			output, err := json.Marshal(recordings)
+			if err != nil {
+				return fmt.Errorf("failed to marshal recordings: %w", err)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(output)
+				return nil
+			}
+
+			return jsoncolor.Write(os.Stdout, bytes.NewReader(output), "  ")
+		},
+	}
+
+	return cmd
+}
+
+func NewCmdRecPlay() *cobra.Command {
+	var flags struct {
+		Speed     float64
+		IdleLimit time.Duration
+		Tab       bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "play <recording>",
+		Short: "Replay a recording in the terminal",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := recordingPath(args[0])
+			if err != nil {
+				return err
+			}
+
+			if flags.Speed <= 0 {
+				return fmt.Errorf("invalid speed: %v", flags.Speed)
+			}
+
+			if flags.Tab {
+				if env := os.Getenv("TWEETY_SOCKET"); env == "" {
+					return fmt.Errorf("TWEETY_SOCKET environment variable must be set")
+				}
+
+				executable, err := os.Executable()
+				if err != nil {
+					return fmt.Errorf("failed to get executable: %w", err)
+				}
+
+				path, err := filepath.Abs(path)
+				if err != nil {
+					return fmt.Errorf("failed to get absolute path: %w", err)
+				}
+
+				return openLaunchTerminal(ttyLaunch{
+					Command: executable,
+					Args: []string{
+						"rec", "play", path,
+						"--speed", fmt.Sprint(flags.Speed),
+						"--idle-limit", flags.IdleLimit.String(),
+					},
+					Cwd:      filepath.Dir(path),
+					Title:    fmt.Sprintf("Replay of %s", strings.TrimSuffix(filepath.Base(path), ".cast")),
+					KeepOpen: true,
+				}, false)
+			}
+
+			var last float64
+			_, err = readCast(path, func(event castEvent) error {
+				delay := time.Duration((event.Time - last) / flags.Speed * float64(time.Second))
+				if flags.IdleLimit > 0 {
+					delay = min(delay, flags.IdleLimit)
+				}
+				last = event.Time
+
+				time.Sleep(delay)
+				if event.Code == "o" {
+					os.Stdout.WriteString(event.Data)
+				}
+
+				return nil
+			})
+
+			return err
+		},
+	}
+
+	cmd.Flags().Float64Var(&flags.Speed, "speed", 1, "Playback speed")
+	cmd.Flags().DurationVar(&flags.IdleLimit, "idle-limit", 2*time.Second, "Maximum time between two events, 0 to keep the recorded pauses")
+	cmd.Flags().BoolVar(&flags.Tab, "tab", false, "Replay the recording in a new terminal tab")
+
+	return cmd
+}
+
+func NewCmdRecExport() *cobra.Command {
+	var flags struct {
+		Format string
+		Output string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "export <recording>",
+		Short: "Export a recording as asciicast or plain text",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := recordingPath(args[0])
+			if err != nil {
+				return err
+			}
+
+			var w io.Writer = os.Stdout
+			if flags.Output != "" {
+				f, err := os.OpenFile(flags.Output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+				if err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				defer f.Close()
+				w = f
+			}
+
+			switch flags.Format {
+			case "cast":
+				f, err := os.Open(path)
+				if err != nil {
+					return fmt.Errorf("failed to open recording: %w", err)
+				}
+				defer f.Close()
+
+				_, err = io.Copy(w, f)
+				return err
+			case "txt":
+				var output bytes.Buffer
+				if _, err := readCast(path, func(event castEvent) error {
+					if event.Code == "o" {
+						output.WriteString(event.Data)
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+
+				_, err := io.WriteString(w, plainText(output.Bytes()))
+				return err
+			default:
+				return fmt.Errorf("invalid format: %s, expected cast or txt", flags.Format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Format, "format", "cast", "Format of the export (cast, txt)")
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "", "Write the export to a file instead of stdout")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"cast", "txt"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
@@ -40,8 +40,9 @@ func NewCmdRoot(version string) *cobra.Command {
 		Args:         cobra.ArbitraryArgs,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			confmapProvider := confmap.Provider(map[string]interface{}{
-				"command": getDefaultShell(),
-				"theme":   "Tomorrow Night",
+				"command":         getDefaultShell(),
+				"theme":           "Tomorrow Night",
+				"notifyThreshold": "10s",
 			}, ".")
 			if err := k.Load(confmapProvider, nil); err != nil {
 				return fmt.Errorf("failed to load default config: %w", err)
@@ -91,13 +92,26 @@ func NewCmdRoot(version string) *cobra.Command {
 		NewCmdServe(),
 		NewCmdInstall(),
 		NewCmdTabs(),
+		NewCmdTabGroups(),
+		NewCmdSession(),
+		NewCmdWorkspace(),
 		NewCmdBookmarks(),
 		NewCmdHistory(),
 		NewCmdWindows(),
 		NewCmdNotifications(),
+		NewCmdNotify(),
+		NewCmdShellInit(),
 		NewCmdRun(),
 		NewCmdOpen(),
+		NewCmdTerm(),
+		NewCmdPane(),
+		NewCmdTTY(),
+		NewCmdRec(),
 		NewCmdFetch(),
+		NewCmdCDP(),
+		NewCmdCookies(),
+		NewCmdDownloads(),
+		NewCmdClipboard(),
 	)
 
 	return cmd
@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -34,46 +38,11 @@ func NewCmdRun() *cobra.Command {
 			return commands, cobra.ShellCompDirectiveNoFileComp
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// First try to find the exact file name
-			entrypoint := filepath.Join(commandDir, args[0])
-			stat, err := os.Stat(entrypoint)
-
-			// If not found, try to find any file that starts with the command name
-			if os.IsNotExist(err) {
-				files, readErr := os.ReadDir(commandDir)
-				if readErr == nil {
-					for _, file := range files {
-						if file.IsDir() {
-							continue
-						}
-
-						name := file.Name()
-						nameWithoutExt := strings.TrimSuffix(name, filepath.Ext(name))
-
-						if nameWithoutExt == args[0] {
-							entrypoint = filepath.Join(commandDir, name)
-							stat, err = os.Stat(entrypoint)
-							break
-						}
-					}
-				}
-			}
-
+			entrypoint, err := findEntrypoint(commandDir, args[0])
 			if err != nil {
 				return fmt.Errorf("unknown command: %s", args[0])
 			}
 
-			if stat.IsDir() {
-				return fmt.Errorf("command entrypoint is a directory, expected a file: %s", entrypoint)
-			}
-
-			// check if the entrypoint is executable
-			if stat.Mode()&0111 == 0 {
-				if err := os.Chmod(entrypoint, 0755); err != nil {
-					return fmt.Errorf("failed to make command entrypoint executable: %w", err)
-				}
-			}
-
 			cmdExec := exec.Command(entrypoint, args[1:]...)
 
 			cmdExec.Stdin = os.Stdin
@@ -87,5 +56,157 @@ func NewCmdRun() *cobra.Command {
 
 	cmd.Flags().SetInterspersed(false)
 	return cmd
+}
+
+// findEntrypoint resolves the script of a command or an app in dir, the extension of the file can be omitted.
+// The script is made executable if needed.
+func findEntrypoint(dir string, name string) (string, error) {
+	entrypoint, stat, err := lookupEntrypoint(dir, name)
+	if err != nil {
+		return "", err
+	}
+
+	// check if the entrypoint is executable
+	if stat.Mode()&0111 == 0 {
+		if err := os.Chmod(entrypoint, 0755); err != nil {
+			return "", fmt.Errorf("failed to make entrypoint executable: %w", err)
+		}
+	}
+
+	return entrypoint, nil
+}
+
+// lookupEntrypoint resolves the script of a command or an app in dir, without changing its mode.
+// Names come from bookmark urls for bookmark commands, so they can't contain path elements.
+func lookupEntrypoint(dir string, name string) (string, os.FileInfo, error) {
+	if name == "" || strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) || strings.Contains(name, "..") {
+		return "", nil, fmt.Errorf("invalid entrypoint name: %s", name)
+	}
+
+	// First try to find the exact file name
+	entrypoint := filepath.Join(dir, name)
+	stat, err := os.Stat(entrypoint)
+
+	// If not found, try to find any file that starts with the name
+	if os.IsNotExist(err) {
+		files, readErr := os.ReadDir(dir)
+		if readErr == nil {
+			for _, file := range files {
+				if file.IsDir() {
+					continue
+				}
+
+				fileName := file.Name()
+				nameWithoutExt := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+
+				if nameWithoutExt == name {
+					entrypoint = filepath.Join(dir, fileName)
+					stat, err = os.Stat(entrypoint)
+					break
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to stat entrypoint: %w", err)
+	}
+
+	if filepath.Dir(entrypoint) != filepath.Clean(dir) {
+		return "", nil, fmt.Errorf("entrypoint is outside of %s: %s", dir, entrypoint)
+	}
+
+	if stat.IsDir() {
+		return "", nil, fmt.Errorf("entrypoint is a directory, expected a file: %s", entrypoint)
+	}
+
+	return entrypoint, stat, nil
+}
+
+// findCommand resolves a command requested by a page, bookmark commands are only run if they are already executable.
+func findCommand(name string) (string, error) {
+	entrypoint, stat, err := lookupEntrypoint(commandDir, name)
+	if err != nil {
+		return "", err
+	}
+
+	if stat.Mode()&0111 == 0 {
+		return "", fmt.Errorf("entrypoint is not executable: %s", entrypoint)
+	}
+
+	return entrypoint, nil
+}
+
+// checkCommandEnv checks the environment passed to a bookmark command by a page, which only holds the context of the tab.
+func checkCommandEnv(env map[string]string) error {
+	for key := range env {
+		if !strings.HasPrefix(key, "TWEETY_") {
+			return fmt.Errorf("invalid environment variable: %s", key)
+		}
+	}
+
+	return nil
+}
+
+// commandOutputLimit bounds the output returned to the extension, which displays it in a notification
+const commandOutputLimit = 64 * 1024
+
+// commandTimeout bounds the run time of bookmark commands displaying their output in a notification
+const commandTimeout = time.Minute
+
+// handleCommandsRun runs a command of the command directory on behalf of the extension, for bookmark commands.
+// The context of the tab the command was invoked from is passed as environment variables.
+func handleCommandsRun(input []byte) (any, error) {
+	var params struct {
+		Command string            `json:"command"`
+		Args    []string          `json:"args"`
+		Env     map[string]string `json:"env"`
+	}
+
+	if err := json.Unmarshal(input, &params); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal commands.run params: %w", err)
+	}
+
+	entrypoint, err := findCommand(params.Command)
+	if err != nil {
+		return nil, fmt.Errorf("unknown command %s: %w", params.Command, err)
+	}
+
+	if err := checkCommandEnv(params.Env); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, entrypoint, params.Args...)
+	// the output is read until the pipe is closed, which background processes of the command could keep open
+	cmd.WaitDelay = time.Second
+	cmd.Dir = os.Getenv("HOME")
+	cmd.Env = os.Environ()
+	for key, value := range k.StringMap("env") {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+	}
+	for key, value := range params.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("command timed out after %s", commandTimeout)
+	}
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to run command: %w", err)
+	}
+
+	if len(output) > commandOutputLimit {
+		output = output[len(output)-commandOutputLimit:]
+	}
 
+	return map[string]any{
+		"output":   string(output),
+		"exitCode": cmd.ProcessState.ExitCode(),
+	}, nil
 }
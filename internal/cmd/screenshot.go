@@ -0,0 +1,341 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/spf13/cobra"
+)
+
+// captureInterval stays above the rate limit of captureVisibleTab (two calls per second in chrome)
+var captureInterval = 600 * time.Millisecond
+
+type pageMetrics struct {
+	ScrollX      float64 `json:"scrollX"`
+	ScrollY      float64 `json:"scrollY"`
+	InnerWidth   float64 `json:"innerWidth"`
+	InnerHeight  float64 `json:"innerHeight"`
+	ScrollWidth  float64 `json:"scrollWidth"`
+	ScrollHeight float64 `json:"scrollHeight"`
+}
+
+type elementRect struct {
+	Left   float64 `json:"left"`
+	Top    float64 `json:"top"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+func NewCmdTabsScreenshot() *cobra.Command {
+	var flags struct {
+		Output   string
+		Format   string
+		Quality  int
+		FullPage bool
+		Selector string
+	}
+
+	cmd := &cobra.Command{
+		Use:   "screenshot [tabID]",
+		Short: "Take a screenshot of a tab",
+		Long: `Take a screenshot of a tab.
+
+The tab is activated before being captured. Without --output, the image is written to stdout,
+or displayed inline when stdout is a terminal supporting the kitty or iTerm2 image protocols.
+In other terminals, such as the terminals of tweety, it is written to a file in the working directory.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.Format != "png" && flags.Format != "jpeg" {
+				return fmt.Errorf("invalid format: %s", flags.Format)
+			}
+
+			output := flags.Output
+			if output == "" && isatty.IsTerminal(os.Stdout.Fd()) && detectImageProtocol() == "" {
+				output = fmt.Sprintf("screenshot-%s.%s", time.Now().Format("20060102-150405"), flags.Format)
+			}
+
+			tabParams := []any{}
+			if len(args) > 0 {
+				tabID, err := strconv.Atoi(args[0])
+				if err != nil {
+					return fmt.Errorf("invalid tab ID: %w", err)
+				}
+				tabParams = append(tabParams, tabID)
+			}
+
+			var tab browserTab
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tabs.get", tabParams, &tab); err != nil {
+				return fmt.Errorf("failed to get tab: %w", err)
+			}
+
+			if !tab.Active {
+				if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tabs.update", []any{tab.ID, map[string]any{"active": true}}, nil); err != nil {
+					return fmt.Errorf("failed to activate tab: %w", err)
+				}
+			}
+
+			var imageBytes []byte
+			if !flags.FullPage && flags.Selector == "" {
+				// the browser encodes the image itself, no need to decode it
+				var err error
+				imageBytes, err = captureVisibleTab(tab.WindowID, flags.Format, flags.Quality)
+				if err != nil {
+					return err
+				}
+			} else {
+				img, err := captureTab(tab, flags.Selector)
+				if err != nil {
+					return err
+				}
+
+				imageBytes, err = encodeImage(img, flags.Format, flags.Quality)
+				if err != nil {
+					return err
+				}
+			}
+
+			if output != "" && output != "-" {
+				if err := os.WriteFile(output, imageBytes, 0644); err != nil {
+					return fmt.Errorf("failed to write screenshot: %w", err)
+				}
+
+				if flags.Output == "" {
+					fmt.Fprintf(os.Stderr, "screenshot written to %s\n", output)
+				}
+
+				return nil
+			}
+
+			if output == "" && isatty.IsTerminal(os.Stdout.Fd()) {
+				return writeInlineImage(os.Stdout, imageBytes, flags.Format)
+			}
+
+			os.Stdout.Write(imageBytes)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "", "File to write the screenshot to (- for stdout)")
+	cmd.Flags().StringVar(&flags.Format, "format", "png", "Image format (png, jpeg)")
+	cmd.Flags().IntVar(&flags.Quality, "quality", 90, "Quality of the jpeg image, between 0 and 100")
+	cmd.Flags().BoolVar(&flags.FullPage, "full-page", false, "Capture the whole page by scrolling through it")
+	cmd.Flags().StringVarP(&flags.Selector, "selector", "s", "", "Only capture the element matching the CSS selector")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"png", "jpeg"}, cobra.ShellCompDirectiveNoFileComp))
+	cmd.MarkFlagsMutuallyExclusive("full-page", "selector")
+
+	return cmd
+}
+
+func captureVisibleTab(windowID int, format string, quality int) ([]byte, error) {
+	options := map[string]any{
+		"format": format,
+	}
+
+	if format == "jpeg" {
+		options["quality"] = quality
+	}
+
+	var dataURL string
+	if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tabs.captureVisibleTab", []any{windowID, options}, &dataURL); err != nil {
+		return nil, fmt.Errorf("failed to capture visible tab: %w", err)
+	}
+
+	return decodeDataURL(dataURL)
+}
+
+// captureTab scrolls through the page to capture the element matching selector, or the whole document if selector is empty.
+func captureTab(tab browserTab, selector string) (image.Image, error) {
+	var metrics pageMetrics
+	if err := executeInjectable(tab.ID, "getPageMetrics", []any{}, &metrics); err != nil {
+		return nil, fmt.Errorf("failed to get page metrics: %w", err)
+	}
+
+	// document coordinates of the area to capture
+	region := elementRect{
+		Left:   metrics.ScrollX,
+		Top:    0,
+		Width:  metrics.InnerWidth,
+		Height: metrics.ScrollHeight,
+	}
+
+	if selector != "" {
+		var rect *elementRect
+		if err := executeInjectable(tab.ID, "getElementRect", []any{selector}, &rect); err != nil {
+			return nil, fmt.Errorf("failed to get element position: %w", err)
+		}
+
+		if rect == nil {
+			return nil, fmt.Errorf("no element matches selector: %s", selector)
+		}
+
+		region = *rect
+	}
+
+	defer executeInjectable(tab.ID, "scrollToPosition", []any{metrics.ScrollX, metrics.ScrollY}, nil)
+
+	var canvas *image.RGBA
+	var scale float64
+	for y := region.Top; y < region.Top+region.Height; {
+		var scrollY float64
+		if err := executeInjectable(tab.ID, "scrollToPosition", []any{metrics.ScrollX, y}, &scrollY); err != nil {
+			return nil, fmt.Errorf("failed to scroll page: %w", err)
+		}
+
+		// leave time for the page to render, and for the capture rate limit to reset
+		time.Sleep(captureInterval)
+
+		pngBytes, err := captureVisibleTab(tab.WindowID, "png", 0)
+		if err != nil {
+			return nil, err
+		}
+
+		viewport, err := png.Decode(bytes.NewReader(pngBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode capture: %w", err)
+		}
+
+		if canvas == nil {
+			// captures are in device pixels, while the page metrics are in css pixels
+			scale = float64(viewport.Bounds().Dx()) / metrics.InnerWidth
+			canvas = image.NewRGBA(image.Rect(0, 0, int(math.Round(region.Width*scale)), int(math.Round(region.Height*scale))))
+		}
+
+		rows := min(region.Top+region.Height, scrollY+metrics.InnerHeight) - y
+		if rows <= 0 {
+			break
+		}
+
+		src := image.Pt(int(math.Round((region.Left-metrics.ScrollX)*scale)), int(math.Round((y-scrollY)*scale)))
+		dst := image.Rect(0, int(math.Round((y-region.Top)*scale)), canvas.Bounds().Dx(), int(math.Round((y-region.Top+rows)*scale)))
+		draw.Draw(canvas, dst, viewport, viewport.Bounds().Min.Add(src), draw.Src)
+
+		y += rows
+	}
+
+	if canvas == nil {
+		return nil, fmt.Errorf("nothing to capture")
+	}
+
+	return canvas, nil
+}
+
+func executeInjectable(tabID int, function string, args []any, result any) error {
+	var results []struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "scripting.executeScript", []any{map[string]any{
+		"target": map[string]any{"tabId": tabID},
+		"func":   function,
+		"args":   args,
+	}}, &results); err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		return fmt.Errorf("no result returned from tab")
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(results[0].Result, result)
+}
+
+func decodeDataURL(dataURL string) ([]byte, error) {
+	_, data, ok := strings.Cut(dataURL, ";base64,")
+	if !ok {
+		return nil, fmt.Errorf("invalid data URL")
+	}
+
+	res, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data URL: %w", err)
+	}
+
+	return res, nil
+}
+
+func encodeImage(img image.Image, format string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, fmt.Errorf("failed to encode jpeg: %w", err)
+		}
+	default:
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("failed to encode png: %w", err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// detectImageProtocol returns the inline image protocol supported by the terminal, if any.
+func detectImageProtocol() string {
+	if os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("TERM") == "xterm-kitty" || os.Getenv("TERM_PROGRAM") == "ghostty" {
+		return "kitty"
+	}
+
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm":
+		return "iterm"
+	}
+
+	return ""
+}
+
+func writeInlineImage(w io.Writer, imageBytes []byte, format string) error {
+	switch detectImageProtocol() {
+	case "iterm":
+		fmt.Fprintf(w, "\x1b]1337;File=inline=1;size=%d;preserveAspectRatio=1:%s\a\n", len(imageBytes), base64.StdEncoding.EncodeToString(imageBytes))
+		return nil
+	case "kitty":
+		// kitty only accepts png data
+		if format != "png" {
+			img, _, err := image.Decode(bytes.NewReader(imageBytes))
+			if err != nil {
+				return fmt.Errorf("failed to decode image: %w", err)
+			}
+
+			imageBytes, err = encodeImage(img, "png", 0)
+			if err != nil {
+				return err
+			}
+		}
+
+		payload := base64.StdEncoding.EncodeToString(imageBytes)
+		for i := 0; i < len(payload); i += 4096 {
+			chunk := payload[i:min(i+4096, len(payload))]
+			more := 0
+			if i+4096 < len(payload) {
+				more = 1
+			}
+
+			if i == 0 {
+				fmt.Fprintf(w, "\x1b_Gf=100,a=T,m=%d;%s\x1b\\", more, chunk)
+			} else {
+				fmt.Fprintf(w, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+			}
+		}
+		fmt.Fprintln(w)
+		return nil
+	}
+
+	return fmt.Errorf("terminal does not support inline images")
+}
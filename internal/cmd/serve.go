@@ -6,6 +6,7 @@ import (
 	"context"
 	"crypto/rand"
 	"embed"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -18,12 +19,12 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/aymanbagabas/go-pty"
 	"github.com/gorilla/websocket"
 	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/pomdtr/tweety/internal/osc"
 	"github.com/spf13/cobra"
 )
 
@@ -56,16 +57,29 @@ func NewCmdServe() *cobra.Command {
 				return fmt.Errorf("failed to get free port: %w", err)
 			}
 
-			ttyMap := make(map[string]pty.Pty)
-			messagingHost := NewMessagingHost(logger, port, ttyMap)
+			ttys := newTTYRegistry()
+			messagingHost := NewMessagingHost(logger, port, ttys)
 
-			handler := NewWebSocketHandler(ttyMap)
+			cdpBridge := NewCDPBridge(logger, messagingHost, port)
+
+			// the url of the devtools endpoint holds its token, so it is only given through the unix socket
+			messagingHost.HandleLocalRequest("host.getInfo", func(input []byte) (any, error) {
+				return map[string]any{
+					"port":        port,
+					"devtoolsUrl": cdpBridge.URL(),
+				}, nil
+			})
+
+			mux := http.NewServeMux()
+			mux.Handle("/tty/", NewWebSocketHandler(ttys))
+			mux.Handle("/share/", NewShareHandler(ttys))
+			mux.Handle("/devtools/", cdpBridge)
 
 			logger.Info("Listening", "port", port)
 
 			server := &http.Server{
-				Addr:    fmt.Sprintf(":%d", port),
-				Handler: handler,
+				Addr:    fmt.Sprintf("127.0.0.1:%d", port),
+				Handler: mux,
 			}
 
 			// Channel to signal when messaging host stops
@@ -90,6 +104,23 @@ func NewCmdServe() *cobra.Command {
 				}
 			}()
 
+			// shares can be exposed on another address, which only serves them
+			if address := k.String("shareAddress"); address != "" {
+				shareMux := http.NewServeMux()
+				shareMux.Handle("/share/", NewShareHandler(ttys))
+				shareServer := &http.Server{
+					Addr:    address,
+					Handler: shareMux,
+				}
+				defer shareServer.Shutdown(context.Background())
+
+				go func() {
+					if err := shareServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+						logger.Error("share server error", "address", address, "error", err)
+					}
+				}()
+			}
+
 			// Wait for either messaging host to stop or server error
 			err = <-done
 			logger.Info("Shutting down server")
@@ -125,9 +156,122 @@ type CommandMetadata struct {
 	TargetUrlPatterns   []string `json:"targetUrlPatterns,omitempty"`
 }
 
-func NewMessagingHost(logger *slog.Logger, port int, ptyMap map[string]pty.Pty) *jsonrpc.Host {
+func NewMessagingHost(logger *slog.Logger, port int, ttys *ttyRegistry) *jsonrpc.Host {
 	messagingHost := jsonrpc.NewHost(logger)
 
+	messagingHost.HandleLocalRequest("downloads.wait", handleDownloadsWait(messagingHost))
+	messagingHost.HandleLocalRequest("notifications.wait", newNotificationTracker(logger, messagingHost).handleWait)
+
+	messagingHost.HandleLocalRequest("tty.prepare", func(input []byte) (any, error) {
+		var launch ttyLaunch
+		if err := json.Unmarshal(input, &launch); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tty.prepare params: %w", err)
+		}
+
+		if !filepath.IsAbs(launch.Command) {
+			return nil, fmt.Errorf("command must be an absolute path: %s", launch.Command)
+		}
+
+		if stat, err := os.Stat(launch.Command); err != nil || stat.IsDir() || stat.Mode()&0111 == 0 {
+			return nil, fmt.Errorf("command is not an executable file: %s", launch.Command)
+		}
+
+		return map[string]any{
+			"nonce": ttys.prepareLaunch(&launch),
+		}, nil
+	})
+
+	handleTTYList := func(input []byte) (any, error) {
+		return ttys.list(), nil
+	}
+	messagingHost.HandleRequest("tty.list", handleTTYList)
+	messagingHost.HandleLocalRequest("tty.list", handleTTYList)
+
+	messagingHost.HandleLocalRequest("tty.get", func(input []byte) (any, error) {
+		var params struct {
+			ID string `json:"id"`
+		}
+
+		if err := json.Unmarshal(input, &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tty.get params: %w", err)
+		}
+
+		session, ok := ttys.get(params.ID)
+		if !ok {
+			return nil, fmt.Errorf("invalid tty ID: %s", params.ID)
+		}
+
+		return ttys.info(session), nil
+	})
+
+	messagingHost.HandleLocalRequest("tty.write", func(input []byte) (any, error) {
+		var params struct {
+			ID   string `json:"id"`
+			Data string `json:"data"`
+		}
+
+		if err := json.Unmarshal(input, &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tty.write params: %w", err)
+		}
+
+		session, ok := ttys.get(params.ID)
+		if !ok {
+			return nil, fmt.Errorf("invalid tty ID: %s", params.ID)
+		}
+
+		session.mu.Lock()
+		session.inputOffset = session.Output.offset()
+		session.inputLines = inputLines(params.Data)
+		session.mu.Unlock()
+
+		if _, err := session.Pty.Write([]byte(params.Data)); err != nil {
+			return nil, fmt.Errorf("failed to write to tty: %w", err)
+		}
+
+		return map[string]any{}, nil
+	})
+
+	messagingHost.HandleLocalRequest("tty.capture", func(input []byte) (any, error) {
+		var params struct {
+			ID    string `json:"id"`
+			Lines int    `json:"lines"`
+			Raw   bool   `json:"raw"`
+			// Since is the offset of the output to capture from, the whole scrollback is captured by default
+			Since int64 `json:"since"`
+		}
+
+		if err := json.Unmarshal(input, &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tty.capture params: %w", err)
+		}
+
+		session, ok := ttys.get(params.ID)
+		if !ok {
+			return nil, fmt.Errorf("invalid tty ID: %s", params.ID)
+		}
+
+		data, _, _ := session.Output.since(params.Since)
+		output := string(data)
+		if !params.Raw {
+			output = plainText(data)
+		}
+
+		if params.Lines > 0 {
+			lines := strings.SplitAfter(output, "\n")
+			if len(lines) > params.Lines {
+				output = strings.Join(lines[len(lines)-params.Lines:], "")
+			}
+		}
+
+		return map[string]string{
+			"output": output,
+		}, nil
+	})
+
+	messagingHost.HandleLocalRequest("tty.wait", handleTTYWait(ttys))
+	messagingHost.HandleLocalRequest("tty.share", handleShare(ttys, port))
+
+	registerPaneHandlers(logger, messagingHost, ttys)
+
 	messagingHost.HandleRequest("initialize", func(input []byte) (any, error) {
 		var params struct {
 			Version   string `json:"version"`
@@ -166,10 +310,16 @@ func NewMessagingHost(logger *slog.Logger, port int, ptyMap map[string]pty.Pty)
 				return
 			}
 
-			resp, err := messagingHost.SendRequest(request)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("failed to send request: %s", err), http.StatusInternalServerError)
-				return
+			var resp jsonrpc.JSONRPCResponse
+			if handler, ok := messagingHost.LocalRequestHandler(request.Method); ok {
+				resp = handleSocketRequest(request, handler)
+			} else {
+				var err error
+				resp, err = messagingHost.SendRequest(request)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("failed to send request: %s", err), http.StatusInternalServerError)
+					return
+				}
 			}
 
 			w.Header().Set("Content-Type", "application/json")
@@ -184,11 +334,12 @@ func NewMessagingHost(logger *slog.Logger, port int, ptyMap map[string]pty.Pty)
 
 	messagingHost.HandleRequest("tty.create", func(input []byte) (any, error) {
 		var params struct {
-			Mode string   `json:"mode"`
-			App  string   `json:"app"`
-			Args []string `json:"args"`
-			Cwd  string   `json:"cwd"`
-			File string   `json:"file"`
+			ttySpec
+			File  string `json:"file"`
+			TabID int    `json:"tabId"`
+			// Layout and Pane are set by the terminal pages, for the tty of one of their panes
+			Layout string `json:"layout"`
+			Pane   string `json:"pane"`
 		}
 
 		if len(input) > 0 {
@@ -197,68 +348,128 @@ func NewMessagingHost(logger *slog.Logger, port int, ptyMap map[string]pty.Pty)
 			}
 		}
 
+		spec := params.ttySpec
+		var attached *ttySession
+		if params.Layout != "" {
+			if params.Pane == "" {
+				return nil, fmt.Errorf("pane is required for a layout")
+			}
+
+			var err error
+			if spec, attached, err = ttys.paneSpec(params.Layout, params.Pane, spec); err != nil {
+				return nil, err
+			}
+		}
+
+		if spec.Mode == "attach" && attached == nil {
+			if spec.TTY == "" {
+				session, ok := ttys.focusedTTY(spec.Tab)
+				if !ok || session.hasExited() {
+					return nil, fmt.Errorf("no terminal found in tab %d", spec.Tab)
+				}
+				attached = session
+			} else {
+				session, ok := ttys.get(spec.TTY)
+				if !ok || session.hasExited() {
+					return nil, fmt.Errorf("invalid tty ID: %s", spec.TTY)
+				}
+				attached = session
+			}
+		}
+
+		// the page shows a running tty, which can be shown in several pages at once
+		if attached != nil {
+			if params.Layout != "" {
+				ttys.setPaneTTY(params.Layout, params.Pane, attached.ID, spec)
+			}
+
+			title := attached.info().Title
+			if spec.launch != nil {
+				title = spec.launch.Title
+			}
+
+			return map[string]string{
+				"url":   fmt.Sprintf("ws://127.0.0.1:%d/tty/%s", port, attached.ID),
+				"id":    attached.ID,
+				"title": title,
+			}, nil
+		}
+
+		if spec.Mode == "exec" && spec.launch == nil {
+			// the command was registered by the cli through tty.prepare, the page only knows its nonce
+			launch, ok := ttys.takeLaunch(spec.Nonce)
+			if !ok {
+				return nil, fmt.Errorf("invalid or expired nonce")
+			}
+
+			spec.launch = launch
+		}
+
 		tty, err := pty.New()
 		if err != nil {
 			log.Printf("failed to create pty: %s", err)
 			return nil, fmt.Errorf("failed to create pty: %w", err)
 		}
 
-		var cmd *pty.Cmd
-		if params.Mode == "app" && params.App != "" {
-			// First try to find the exact file name
-			entrypoint := filepath.Join(appDir, params.App)
-			stat, err := os.Stat(entrypoint)
-
-			// If not found, try to find any file that starts with the app name
-			if os.IsNotExist(err) {
-				files, readErr := os.ReadDir(appDir)
-				if readErr == nil {
-					for _, file := range files {
-						if file.IsDir() {
-							continue
-						}
-
-						name := file.Name()
-						nameWithoutExt := strings.TrimSuffix(name, filepath.Ext(name))
+		ttyID := strings.ToLower(rand.Text())
 
-						if nameWithoutExt == params.App {
-							entrypoint = filepath.Join(appDir, name)
-							stat, err = os.Stat(entrypoint)
-							break
-						}
-					}
-				}
+		var cmd *pty.Cmd
+		var title string
+		env, cwd := spec.Env, spec.Cwd
+		if spec.Mode == "app" && spec.App != "" {
+			entrypoint, err := findEntrypoint(appDir, spec.App)
+			if err != nil {
+				return nil, fmt.Errorf("invalid app %s: %w", spec.App, err)
 			}
 
-			if err != nil {
-				return nil, fmt.Errorf("failed to stat app entrypoint: %w", err)
+			cmd = tty.Command(entrypoint, spec.Args...)
+		} else if spec.Mode == "exec" {
+			launch := spec.launch
+			if launch.KeepOpen {
+				// the command and its arguments are passed as positional parameters, so they don't need quoting
+				script := `"$@"; printf '\n[process exited with code %d, press enter to close]' $?; read -r _`
+				cmd = tty.Command("/bin/sh", append([]string{"-c", script, "sh", launch.Command}, launch.Args...)...)
+			} else {
+				cmd = tty.Command(launch.Command, launch.Args...)
 			}
 
-			if stat.IsDir() {
-				return nil, fmt.Errorf("app entrypoint is a directory, expected a file: %s", entrypoint)
+			env = launch.Env
+			cwd = launch.Cwd
+			title = launch.Title
+		} else if spec.Mode == "command" && spec.Command != "" {
+			entrypoint, err := findCommand(spec.Command)
+			if err != nil {
+				return nil, fmt.Errorf("invalid command %s: %w", spec.Command, err)
 			}
 
-			// check if the entrypoint is executable
-			if stat.Mode()&0111 == 0 {
-				if err := os.Chmod(entrypoint, 0755); err != nil {
-					return nil, fmt.Errorf("failed to make app entrypoint executable: %w", err)
-				}
+			if err := checkCommandEnv(spec.Env); err != nil {
+				return nil, err
 			}
 
-			cmd = tty.Command(entrypoint, params.Args...)
+			cmd = tty.Command(entrypoint, spec.Args...)
 		} else {
 			cmd = tty.Command(k.String("command"), k.Strings("args")...)
 		}
 
+		// keep-open commands are listed without their shell wrapper
+		command := cmd.Args
+		if spec.Mode == "exec" {
+			command = append([]string{spec.launch.Command}, spec.launch.Args...)
+		}
+
 		cmd.Env = os.Environ()
 		cmd.Env = append(cmd.Env, "TERM=xterm-256color")
 		cmd.Env = append(cmd.Env, "TERM_PROGRAM=tweety")
+		cmd.Env = append(cmd.Env, fmt.Sprintf("TWEETY_TTY=%s", ttyID))
 		for key, value := range k.StringMap("env") {
 			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
 		}
+		for key, value := range env {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+		}
 
-		if params.Cwd != "" {
-			cmd.Dir = params.Cwd
+		if cwd != "" {
+			cmd.Dir = cwd
 		} else {
 			cmd.Dir = os.Getenv("HOME")
 		}
@@ -268,35 +479,73 @@ func NewMessagingHost(logger *slog.Logger, port int, ptyMap map[string]pty.Pty)
 			return nil, fmt.Errorf("failed to start pty: %w", err)
 		}
 
-		ttyID := strings.ToLower(rand.Text())
-		ptyMap[ttyID] = tty
+		// the host keeps the slave end open, reading the pty only fails once it is closed, after the program exits
+		go func() {
+			cmd.Wait()
+			if unixPty, ok := tty.(pty.UnixPty); ok {
+				unixPty.Slave().Close()
+			}
+		}()
+
+		var rec *recorder
+		if k.Bool("record") || spec.Record || (spec.launch != nil && spec.launch.Record) {
+			if rec, err = newRecorder(ttyID, castHeader{
+				Title: title,
+				Env: map[string]string{
+					"TERM":  "xterm-256color",
+					"SHELL": k.String("command"),
+				},
+			}); err != nil {
+				logger.Error("failed to start recording", "tty", ttyID, "error", err)
+			}
+		}
+
+		session := &ttySession{
+			ID:       ttyID,
+			TabID:    params.TabID,
+			Pty:      tty,
+			LayoutID: params.Layout,
+			PaneID:   params.Pane,
+			Command:  command,
+			Output:   newScrollback(),
+			Recorder: rec,
+		}
+		ttys.add(session)
+		if params.Layout != "" {
+			ttys.setPaneTTY(params.Layout, params.Pane, ttyID, spec)
+		}
+
+		go session.readLoop(func(seq osc.Sequence) {
+			handleSequence(logger, messagingHost, ttys, ttyID, seq)
+		})
 
 		return map[string]string{
-			"url": fmt.Sprintf("ws://127.0.0.1:%d/tty/%s", port, ttyID),
-			"id":  ttyID,
+			"url":   fmt.Sprintf("ws://127.0.0.1:%d/tty/%s", port, ttyID),
+			"id":    ttyID,
+			"title": title,
 		}, nil
 	})
 
+	messagingHost.HandleRequest("commands.run", handleCommandsRun)
+	messagingHost.SetTimeout("tabs.printToPDF", pdfTimeout)
+
 	messagingHost.HandleNotification("tty.resize", func(input []byte) error {
 		var requestParams struct {
-			TTY  string `json:"tty"`
-			Rows int    `json:"rows"`
-			Cols int    `json:"cols"`
+			TTY    string `json:"tty"`
+			Client string `json:"client"`
+			Rows   int    `json:"rows"`
+			Cols   int    `json:"cols"`
 		}
 		if err := json.Unmarshal(input, &requestParams); err != nil {
 			return fmt.Errorf("failed to unmarshal resize params: %w", err)
 		}
 
-		tty, ok := ptyMap[requestParams.TTY]
+		session, ok := ttys.get(requestParams.TTY)
 		if !ok {
 			return fmt.Errorf("invalid tty ID: %s", requestParams.TTY)
 		}
 
-		if err := tty.Resize(requestParams.Cols, requestParams.Rows); err != nil {
-			return fmt.Errorf("failed to set size for tty: %w", err)
-		}
-
-		return nil
+		return session.resize(requestParams.Client, requestParams.Cols, requestParams.Rows)
 	})
 
 	messagingHost.HandleRequest("xterm.getConfig", func(input []byte) (any, error) {
@@ -308,33 +557,7 @@ func NewMessagingHost(logger *slog.Logger, port int, ptyMap map[string]pty.Pty)
 			return nil, fmt.Errorf("failed to unmarshal xterm config params: %w", err)
 		}
 
-		var theme string
-		if darkTheme := k.String("themeDark"); params.Variant == "dark" && darkTheme != "" {
-			theme = darkTheme
-		} else {
-			theme = k.String("theme")
-		}
-
-		themeBytes, err := themeFs.ReadFile(filepath.Join("themes", theme+".json"))
-		if err != nil {
-			return nil, fmt.Errorf("failed to read theme file: %w", err)
-		}
-
-		xtermConfig := map[string]interface{}{
-			"cursorBlink":                   true,
-			"allowProposedApi":              true,
-			"macOptionIsMeta":               true,
-			"macOptionClickForcesSelection": true,
-			"fontSize":                      13,
-			"fontFamily":                    "Consolas,Liberation Mono,Menlo,Courier,monospace",
-			"theme":                         json.RawMessage(themeBytes),
-		}
-
-		if err := k.Unmarshal("xterm", &xtermConfig); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal xterm config: %w", err)
-		}
-
-		return xtermConfig, nil
+		return getXtermConfig(params.Variant)
 	})
 
 	messagingHost.HandleRequest("readFile", func(input []byte) (any, error) {
@@ -386,25 +609,158 @@ func NewMessagingHost(logger *slog.Logger, port int, ptyMap map[string]pty.Pty)
 	return messagingHost
 }
 
-func NewWebSocketHandler(ptyMap map[string]pty.Pty) http.Handler {
+func handleSocketRequest(request jsonrpc.JSONRPCRequest, handler jsonrpc.RequestHandlerFunc) jsonrpc.JSONRPCResponse {
+	resp := jsonrpc.JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+	}
+
+	res, err := handler(request.Params)
+	if err != nil {
+		resp.Error, _ = json.Marshal(map[string]any{
+			"code":    -32603,
+			"message": fmt.Sprintf("Internal error: %s", err),
+		})
+		return resp
+	}
+
+	resultBytes, err := json.Marshal(res)
+	if err != nil {
+		resp.Error, _ = json.Marshal(map[string]any{
+			"code":    -32603,
+			"message": fmt.Sprintf("failed to marshal result: %s", err),
+		})
+		return resp
+	}
+
+	resp.Result = resultBytes
+	return resp
+}
+
+// getXtermConfig returns the options of the xterm.js terminals, with the theme of the light or dark variant.
+func getXtermConfig(variant string) (map[string]any, error) {
+	var theme string
+	if darkTheme := k.String("themeDark"); variant == "dark" && darkTheme != "" {
+		theme = darkTheme
+	} else {
+		theme = k.String("theme")
+	}
+
+	themeBytes, err := themeFs.ReadFile(filepath.Join("themes", theme+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read theme file: %w", err)
+	}
+
+	xtermConfig := map[string]any{
+		"cursorBlink":                   true,
+		"allowProposedApi":              true,
+		"macOptionIsMeta":               true,
+		"macOptionClickForcesSelection": true,
+		"fontSize":                      13,
+		"fontFamily":                    "Consolas,Liberation Mono,Menlo,Courier,monospace",
+		"theme":                         json.RawMessage(themeBytes),
+	}
+
+	if err := k.Unmarshal("xterm", &xtermConfig); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal xterm config: %w", err)
+	}
+
+	return xtermConfig, nil
+}
+
+// handleSequence reacts to the operating system commands emitted by the programs running in a tty.
+func handleSequence(logger *slog.Logger, messagingHost *jsonrpc.Host, ttys *ttyRegistry, ttyID string, seq osc.Sequence) {
+	switch seq.Command {
+	case "0", "2":
+		if session, ok := ttys.get(ttyID); ok {
+			session.setTitle(seq.Data)
+		}
+	case "7":
+		if session, ok := ttys.get(ttyID); ok {
+			session.setCwd(seq.Data)
+		}
+	case "133":
+		if session, ok := ttys.get(ttyID); ok {
+			handlePromptMark(logger, messagingHost, session, seq.Data)
+		}
+	case "52":
+		// ESC ] 52 ; <selection> ; <base64 data> BEL, a "?" payload queries the clipboard, which is not supported
+		_, payload, _ := strings.Cut(seq.Data, ";")
+		if payload == "?" {
+			return
+		}
+
+		data, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			logger.Error("invalid clipboard sequence", "tty", ttyID, "error", err)
+			return
+		}
+
+		go func() {
+			if err := messagingHost.Call("clipboard.write", []any{map[string]any{
+				"data":     string(data),
+				"mimeType": "text/plain",
+			}}, nil); err != nil {
+				logger.Error("failed to write clipboard", "tty", ttyID, "error", err)
+			}
+		}()
+	}
+}
+
+func NewWebSocketHandler(ttys *ttyRegistry) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ttyID := strings.TrimPrefix(r.URL.Path, "/tty/")
-		tty, ok := ptyMap[ttyID]
+		session, ok := ttys.get(ttyID)
 		if !ok {
 			http.Error(w, fmt.Sprintf("invalid terminal ID: %s", ttyID), http.StatusBadRequest)
 			return
 		}
 
+		// pages report their size with the ID of their client, the tty takes the smallest one
+		client := r.URL.Query().Get("client")
+		session.connectPage()
 		defer func() {
-			delete(ptyMap, ttyID)
-			tty.Close()
+			if !session.disconnectPage(client) {
+				return
+			}
+
+			// the tty is closed once its program exits, or once no page is connected to it for ttyDetachTimeout
+			closeTTY := func() {
+				if session.idle() {
+					ttys.remove(ttyID)
+					session.Pty.Close()
+				}
+			}
+
+			if session.hasExited() {
+				closeTTY()
+				return
+			}
+
+			time.AfterFunc(ttyDetachTimeout, closeTTY)
 		}()
 
-		HandleWebsocket(ptyMap[ttyID])(w, r)
+		HandleWebsocket(session, ttyClientOptions{})(w, r)
 	})
 }
 
-func HandleWebsocket(tty pty.Pty) http.HandlerFunc {
+// ttyClientOptions configure the websocket connection of a client to a tty.
+type ttyClientOptions struct {
+	// ReadOnly ignores the input of the client
+	ReadOnly bool
+	// Resize sends the size of the terminal to the client, as json text messages
+	Resize bool
+	// Expires closes the connection at this time, if set
+	Expires time.Time
+}
+
+// ttyCloseSlowClient is the close code of the connections of clients too slow to keep up with the output of the tty.
+// The program is still running, pages attach to the tty again. Connections are closed with a normal closure once the
+// program exits.
+const ttyCloseSlowClient = 4000
+
+// HandleWebsocket connects a client to a tty: the client receives the output kept so far, then the output of the tty as it is read.
+func HandleWebsocket(session *ttySession, options ttyClientOptions) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		log.Print("established connection identity")
 		upgrader := getConnectionUpgrader(maxBufferSizeBytes)
@@ -415,84 +771,110 @@ func HandleWebsocket(tty pty.Pty) http.HandlerFunc {
 		}
 		defer connection.Close()
 
-		var waiter sync.WaitGroup
-		waiter.Add(1)
+		backlog, cols, rows, events, detach := session.attach()
+		defer detach()
 
+		done := make(chan struct{})
 		// tty << xterm.js
 		go func() {
+			defer close(done)
 			for {
 				// data processing
 				_, data, err := connection.ReadMessage()
 				if err != nil {
 					log.Printf("failed to get next reader: %s", err)
-					waiter.Done()
 					return
 				}
-				dataLength := len(data)
-				dataBuffer := bytes.Trim(data, "\x00")
 
-				// process
-				if dataLength == -1 { // invalid
-					log.Printf("failed to get the correct number of bytes read, ignoring message")
+				if options.ReadOnly {
 					continue
 				}
 
+				dataBuffer := bytes.Trim(data, "\x00")
+
 				// write to tty
-				if _, err := tty.Write(dataBuffer); err != nil {
+				if _, err := session.Pty.Write(dataBuffer); err != nil {
 					log.Printf("failed to write %v bytes to tty: %s", len(dataBuffer), err)
 					continue
 				}
 			}
 		}()
 
-		messages := make(chan []byte)
-		// tty >> xterm.js
-		go func() {
-			for {
-				buffer := make([]byte, maxBufferSizeBytes)
-				readLength, err := tty.Read(buffer)
-				if err != nil {
-					connection.Close()
-					log.Printf("failed to read from tty: %s", err)
-					return
-				}
-
-				messages <- buffer[:readLength]
-			}
-		}()
-
 		lastPingTime := time.Now()
 		connection.SetPongHandler(func(appData string) error {
 			lastPingTime = time.Now()
 			return nil
 		})
 
+		var expires <-chan time.Time
+		if !options.Expires.IsZero() {
+			timer := time.NewTimer(time.Until(options.Expires))
+			defer timer.Stop()
+			expires = timer.C
+		}
+
+		if options.Resize && cols > 0 && rows > 0 {
+			if err := connection.WriteJSON(map[string]int{"cols": cols, "rows": rows}); err != nil {
+				log.Printf("failed to send size to client: %s", err)
+				return
+			}
+		}
+
+		if len(backlog) > 0 {
+			if err := connection.WriteMessage(websocket.BinaryMessage, backlog); err != nil {
+				log.Printf("failed to send %v bytes of scrollback to client", len(backlog))
+				return
+			}
+		}
+
 		// this is a keep-alive loop that ensures connection does not hang-up itself
-		go func() {
-			ticker := time.NewTicker(keepalivePingTimeout / 2)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ticker.C:
-					if err := connection.WriteMessage(websocket.PingMessage, []byte("keepalive")); err != nil {
-						log.Printf("failed to write ping message")
+		ticker := time.NewTicker(keepalivePingTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-expires:
+				log.Printf("connection expired, closing connection")
+				return
+			case <-ticker.C:
+				if err := connection.WriteMessage(websocket.PingMessage, []byte("keepalive")); err != nil {
+					log.Printf("failed to write ping message")
+				}
+
+				if time.Since(lastPingTime) > keepalivePingTimeout {
+					log.Printf("connection timeout, closing connection")
+					return
+				}
+			case event, ok := <-events:
+				if !ok {
+					// the program exited, or the client was too slow to keep up and can attach again
+					code, text := websocket.CloseNormalClosure, "program exited"
+					if !session.hasExited() {
+						code, text = ttyCloseSlowClient, "client too slow"
 					}
 
-					if time.Since(lastPingTime) > keepalivePingTimeout {
-						log.Printf("connection timeout, closing connection")
-						connection.Close()
-						return
+					if err := connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(time.Second)); err != nil {
+						log.Printf("failed to send close message: %s", err)
 					}
-				case m := <-messages:
-					if err := connection.WriteMessage(websocket.BinaryMessage, m); err != nil {
-						log.Printf("failed to send %v bytes from tty to xterm.js", len(m))
-						continue
+					return
+				}
+
+				if event.Output == nil {
+					if options.Resize {
+						if err := connection.WriteJSON(map[string]int{"cols": event.Cols, "rows": event.Rows}); err != nil {
+							log.Printf("failed to send size to client: %s", err)
+						}
 					}
+					continue
 				}
-			}
-		}()
 
-		waiter.Wait()
+				if err := connection.WriteMessage(websocket.BinaryMessage, event.Output); err != nil {
+					log.Printf("failed to send %v bytes from tty to xterm.js", len(event.Output))
+					continue
+				}
+			}
+		}
 	}
 }
 
@@ -0,0 +1,425 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/spf13/cobra"
+)
+
+type Session struct {
+	Windows []SessionWindow `json:"windows"`
+}
+
+type SessionWindow struct {
+	Left      int            `json:"left"`
+	Top       int            `json:"top"`
+	Width     int            `json:"width"`
+	Height    int            `json:"height"`
+	State     string         `json:"state,omitempty"`
+	Type      string         `json:"type,omitempty"`
+	Incognito bool           `json:"incognito,omitempty"`
+	Focused   bool           `json:"focused,omitempty"`
+	Groups    []SessionGroup `json:"groups,omitempty"`
+	Tabs      []SessionTab   `json:"tabs"`
+}
+
+type SessionGroup struct {
+	ID        int    `json:"id"`
+	Title     string `json:"title,omitempty"`
+	Color     string `json:"color,omitempty"`
+	Collapsed bool   `json:"collapsed,omitempty"`
+}
+
+type SessionTab struct {
+	URL    string `json:"url"`
+	Pinned bool   `json:"pinned,omitempty"`
+	Active bool   `json:"active,omitempty"`
+	// Group references SessionGroup.ID within the same window, 0 means ungrouped
+	Group int `json:"group,omitempty"`
+}
+
+// browserWindow and browserTab mirror the fields of chrome.windows.Window and chrome.tabs.Tab we rely on.
+type browserWindow struct {
+	ID        int    `json:"id"`
+	Left      int    `json:"left"`
+	Top       int    `json:"top"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	State     string `json:"state"`
+	Type      string `json:"type"`
+	Incognito bool   `json:"incognito"`
+	Focused   bool   `json:"focused"`
+}
+
+type browserTab struct {
+	ID         int    `json:"id"`
+	WindowID   int    `json:"windowId"`
+	Index      int    `json:"index"`
+	URL        string `json:"url"`
+	PendingURL string `json:"pendingUrl"`
+	Title      string `json:"title"`
+	Pinned     bool   `json:"pinned"`
+	Active     bool   `json:"active"`
+	GroupID    int    `json:"groupId"`
+}
+
+type browserTabGroup struct {
+	ID        int    `json:"id"`
+	WindowID  int    `json:"windowId"`
+	Title     string `json:"title"`
+	Color     string `json:"color"`
+	Collapsed bool   `json:"collapsed"`
+}
+
+func NewCmdSession() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "session",
+		Aliases: []string{"sessions"},
+		Short:   "Save and restore browser windows and tabs",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if env := os.Getenv("TWEETY_SOCKET"); env == "" {
+				return fmt.Errorf("TWEETY_SOCKET environment variable must be set")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.AddCommand(
+		NewCmdSessionSave(),
+		NewCmdSessionRestore(),
+	)
+
+	return cmd
+}
+
+func NewCmdSessionSave() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "save <file>",
+		Short: "Snapshot all windows and tabs to a file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, err := snapshotSession()
+			if err != nil {
+				return err
+			}
+
+			sessionBytes, err := json.MarshalIndent(session, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal session: %w", err)
+			}
+
+			if args[0] == "-" {
+				os.Stdout.Write(sessionBytes)
+				os.Stdout.WriteString("\n")
+				return nil
+			}
+
+			if err := os.WriteFile(args[0], sessionBytes, 0644); err != nil {
+				return fmt.Errorf("failed to write session file: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func NewCmdSessionRestore() *cobra.Command {
+	var flags struct {
+		Merge   bool
+		Replace bool
+		Diff    bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Recreate the windows and tabs of a snapshot",
+		Long: `Recreate the windows and tabs of a snapshot.
+
+By default, every window of the snapshot is opened next to the existing ones.
+With --merge, tabs whose URL is already open are skipped.
+With --replace, windows that were open before the restore are closed afterwards.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sessionBytes, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to read session file: %w", err)
+			}
+
+			var session Session
+			if err := json.Unmarshal(sessionBytes, &session); err != nil {
+				return fmt.Errorf("failed to parse session file: %w", err)
+			}
+
+			current, err := snapshotSession()
+			if err != nil {
+				return err
+			}
+
+			if flags.Merge {
+				session = subtractSession(session, current)
+			}
+
+			if flags.Diff {
+				printSessionDiff(session, current, flags.Replace)
+				return nil
+			}
+
+			var previousWindows []browserWindow
+			if flags.Replace {
+				if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "windows.getAll", []any{}, &previousWindows); err != nil {
+					return fmt.Errorf("failed to list windows: %w", err)
+				}
+			}
+
+			for _, window := range session.Windows {
+				if len(window.Tabs) == 0 {
+					continue
+				}
+
+				if err := restoreWindow(window); err != nil {
+					return err
+				}
+			}
+
+			for _, window := range previousWindows {
+				if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "windows.remove", []any{window.ID}, nil); err != nil {
+					return fmt.Errorf("failed to close window %d: %w", window.ID, err)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.Merge, "merge", false, "Skip tabs that are already open")
+	cmd.Flags().BoolVar(&flags.Replace, "replace", false, "Close the existing windows after restoring")
+	cmd.Flags().BoolVar(&flags.Diff, "diff", false, "Show what a restore would change without applying it")
+	cmd.MarkFlagsMutuallyExclusive("merge", "replace")
+
+	return cmd
+}
+
+func snapshotSession() (Session, error) {
+	socketPath := os.Getenv("TWEETY_SOCKET")
+
+	var windows []browserWindow
+	if err := jsonrpc.Call(socketPath, "windows.getAll", []any{}, &windows); err != nil {
+		return Session{}, fmt.Errorf("failed to list windows: %w", err)
+	}
+
+	var tabs []browserTab
+	if err := jsonrpc.Call(socketPath, "tabs.query", []any{map[string]any{}}, &tabs); err != nil {
+		return Session{}, fmt.Errorf("failed to list tabs: %w", err)
+	}
+
+	// tab groups are not available in every browser, so a failure here only means no groups are saved
+	var groups []browserTabGroup
+	if err := jsonrpc.Call(socketPath, "tabGroups.query", []any{map[string]any{}}, &groups); err != nil {
+		groups = nil
+	}
+
+	var session Session
+	for _, window := range windows {
+		sessionWindow := SessionWindow{
+			Left:      window.Left,
+			Top:       window.Top,
+			Width:     window.Width,
+			Height:    window.Height,
+			State:     window.State,
+			Type:      window.Type,
+			Incognito: window.Incognito,
+			Focused:   window.Focused,
+			Tabs:      []SessionTab{},
+		}
+
+		// group IDs are not stable across restarts, so tabs reference their group by a window-local ID
+		groupIDs := make(map[int]int)
+		for _, group := range groups {
+			if group.WindowID != window.ID {
+				continue
+			}
+
+			groupIDs[group.ID] = len(sessionWindow.Groups) + 1
+			sessionWindow.Groups = append(sessionWindow.Groups, SessionGroup{
+				ID:        groupIDs[group.ID],
+				Title:     group.Title,
+				Color:     group.Color,
+				Collapsed: group.Collapsed,
+			})
+		}
+
+		for _, tab := range tabs {
+			if tab.WindowID != window.ID {
+				continue
+			}
+
+			url := tab.URL
+			if url == "" {
+				url = tab.PendingURL
+			}
+
+			// tabs.query returns tabs ordered by window, then by index
+			sessionWindow.Tabs = append(sessionWindow.Tabs, SessionTab{
+				URL:    url,
+				Pinned: tab.Pinned,
+				Active: tab.Active,
+				Group:  groupIDs[tab.GroupID],
+			})
+		}
+
+		session.Windows = append(session.Windows, sessionWindow)
+	}
+
+	return session, nil
+}
+
+func subtractSession(session Session, current Session) Session {
+	openURLs := make(map[string]bool)
+	for _, window := range current.Windows {
+		for _, tab := range window.Tabs {
+			openURLs[tab.URL] = true
+		}
+	}
+
+	var res Session
+	for _, window := range session.Windows {
+		var tabs []SessionTab
+		for _, tab := range window.Tabs {
+			if openURLs[tab.URL] {
+				continue
+			}
+
+			tabs = append(tabs, tab)
+		}
+
+		if len(tabs) == 0 {
+			continue
+		}
+
+		window.Tabs = tabs
+		res.Windows = append(res.Windows, window)
+	}
+
+	return res
+}
+
+func printSessionDiff(session Session, current Session, replace bool) {
+	for i, window := range session.Windows {
+		fmt.Printf("+ window %d (%dx%d, %s)\n", i+1, window.Width, window.Height, window.State)
+		for _, tab := range window.Tabs {
+			fmt.Printf("+   %s\n", tab.URL)
+		}
+	}
+
+	if !replace {
+		return
+	}
+
+	for i, window := range current.Windows {
+		fmt.Printf("- window %d (%dx%d, %s)\n", i+1, window.Width, window.Height, window.State)
+		for _, tab := range window.Tabs {
+			fmt.Printf("-   %s\n", tab.URL)
+		}
+	}
+}
+
+func restoreWindow(window SessionWindow) error {
+	socketPath := os.Getenv("TWEETY_SOCKET")
+
+	var urls []string
+	for _, tab := range window.Tabs {
+		urls = append(urls, tab.URL)
+	}
+
+	options := map[string]any{
+		"url":     urls,
+		"focused": window.Focused,
+	}
+
+	if window.Type != "" {
+		options["type"] = window.Type
+	}
+
+	if window.Incognito {
+		options["incognito"] = true
+	}
+
+	// bounds can only be combined with the normal state, the final state is applied once the tabs are set up
+	if window.Width > 0 && window.Height > 0 {
+		options["left"] = window.Left
+		options["top"] = window.Top
+		options["width"] = window.Width
+		options["height"] = window.Height
+	}
+
+	var created browserWindow
+	if err := jsonrpc.Call(socketPath, "windows.create", []any{options}, &created); err != nil {
+		return fmt.Errorf("failed to create window: %w", err)
+	}
+
+	var tabs []browserTab
+	if err := jsonrpc.Call(socketPath, "tabs.query", []any{map[string]any{"windowId": created.ID}}, &tabs); err != nil {
+		return fmt.Errorf("failed to list tabs of window %d: %w", created.ID, err)
+	}
+
+	if len(tabs) != len(window.Tabs) {
+		return fmt.Errorf("expected %d tabs in window %d, found %d", len(window.Tabs), created.ID, len(tabs))
+	}
+
+	groupTabs := make(map[int][]int)
+	for i, tab := range window.Tabs {
+		if tab.Pinned || tab.Active {
+			if err := jsonrpc.Call(socketPath, "tabs.update", []any{tabs[i].ID, map[string]any{
+				"pinned": tab.Pinned,
+				"active": tab.Active,
+			}}, nil); err != nil {
+				return fmt.Errorf("failed to update tab %d: %w", tabs[i].ID, err)
+			}
+		}
+
+		if tab.Group != 0 {
+			groupTabs[tab.Group] = append(groupTabs[tab.Group], tabs[i].ID)
+		}
+	}
+
+	for _, group := range window.Groups {
+		tabIds, ok := groupTabs[group.ID]
+		if !ok {
+			continue
+		}
+
+		var groupID int
+		if err := jsonrpc.Call(socketPath, "tabs.group", []any{map[string]any{
+			"tabIds": tabIds,
+			"createProperties": map[string]any{
+				"windowId": created.ID,
+			},
+		}}, &groupID); err != nil {
+			return fmt.Errorf("failed to group tabs: %w", err)
+		}
+
+		if err := jsonrpc.Call(socketPath, "tabGroups.update", []any{groupID, map[string]any{
+			"title":     group.Title,
+			"color":     group.Color,
+			"collapsed": group.Collapsed,
+		}}, nil); err != nil {
+			return fmt.Errorf("failed to update tab group %d: %w", groupID, err)
+		}
+	}
+
+	if window.State != "" && window.State != "normal" {
+		if err := jsonrpc.Call(socketPath, "windows.update", []any{created.ID, map[string]any{
+			"state": window.State,
+		}}, nil); err != nil {
+			return fmt.Errorf("failed to update window state: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/spf13/cobra"
+)
+
+// defaultShareExpiry is the lifetime of a share, unless set by tweety tty share --expires
+const defaultShareExpiry = time.Hour
+
+// ttyShare gives access to a tty to the holders of its token, until it expires.
+type ttyShare struct {
+	Token string
+	TTY   string
+	// Write lets the viewers send input to the tty
+	Write   bool
+	Expires time.Time
+}
+
+func (r *ttyRegistry) addShare(share *ttyShare) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for token, s := range r.shares {
+		if time.Now().After(s.Expires) {
+			delete(r.shares, token)
+		}
+	}
+
+	r.shares[share.Token] = share
+}
+
+func (r *ttyRegistry) getShare(token string) (*ttyShare, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	share, ok := r.shares[token]
+	if !ok || time.Now().After(share.Expires) {
+		return nil, false
+	}
+
+	return share, true
+}
+
+// shareBaseURL returns the url the shares are served on: the shareAddress setting if set,
+// else the local address of the host.
+func shareBaseURL(port int) string {
+	address := k.String("shareAddress")
+	if address == "" {
+		return fmt.Sprintf("http://127.0.0.1:%d", port)
+	}
+
+	// a share listening on all interfaces is reached through the hostname of the machine
+	host, sharePort, err := net.SplitHostPort(address)
+	if err == nil && (host == "" || host == "0.0.0.0" || host == "::") {
+		if hostname, err := os.Hostname(); err == nil {
+			address = net.JoinHostPort(hostname, sharePort)
+		}
+	}
+
+	return "http://" + address
+}
+
+// handleShare returns the handler of tty.share, which creates a share of a tty and returns its url.
+func handleShare(ttys *ttyRegistry, port int) jsonrpc.RequestHandlerFunc {
+	return func(input []byte) (any, error) {
+		var params struct {
+			ID      string `json:"id"`
+			Write   bool   `json:"write"`
+			Expires string `json:"expires"`
+		}
+
+		if err := json.Unmarshal(input, &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tty.share params: %w", err)
+		}
+
+		if _, ok := ttys.get(params.ID); !ok {
+			return nil, fmt.Errorf("invalid tty ID: %s", params.ID)
+		}
+
+		expiry := defaultShareExpiry
+		if params.Expires != "" {
+			var err error
+			if expiry, err = time.ParseDuration(params.Expires); err != nil || expiry <= 0 {
+				return nil, fmt.Errorf("invalid expiry: %s", params.Expires)
+			}
+		}
+
+		share := &ttyShare{
+			Token:   rand.Text(),
+			TTY:     params.ID,
+			Write:   params.Write,
+			Expires: time.Now().Add(expiry),
+		}
+		ttys.addShare(share)
+
+		return map[string]any{
+			"url":     fmt.Sprintf("%s/share/%s", shareBaseURL(port), share.Token),
+			"write":   share.Write,
+			"expires": share.Expires,
+		}, nil
+	}
+}
+
+var shareTemplate = template.Must(template.ParseFS(embedFs, "embed/share.html"))
+
+// xtermFs holds the xterm.js assets of the share page, so that the page doesn't depend on a cdn.
+// They are vendored from the dependencies of the extension by scripts/vendor-xterm.sh.
+//
+//go:embed xterm/xterm.js xterm/xterm.css
+var xtermFs embed.FS
+
+var shareAssets, _ = fs.Sub(xtermFs, "xterm")
+
+// NewShareHandler serves the shares of ttys: /share/<token> is a page showing the terminal,
+// which connects to the websocket at /share/<token>/ws. The page loads xterm.js from /share/assets.
+func NewShareHandler(ttys *ttyRegistry) http.Handler {
+	assets := http.StripPrefix("/share/assets/", http.FileServerFS(shareAssets))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/share/assets/") {
+			assets.ServeHTTP(w, r)
+			return
+		}
+
+		token, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/share/"), "/")
+		share, ok := ttys.getShare(token)
+		if !ok {
+			http.Error(w, "share not found or expired", http.StatusNotFound)
+			return
+		}
+
+		session, ok := ttys.get(share.TTY)
+		if !ok {
+			http.Error(w, "the terminal is closed", http.StatusGone)
+			return
+		}
+
+		switch rest {
+		case "":
+			config, err := getXtermConfig("dark")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			title := session.info().Title
+			if title == "" {
+				title = "Shared terminal"
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			if err := shareTemplate.Execute(w, map[string]any{
+				"Title":  title,
+				"Config": config,
+				"Write":  share.Write,
+			}); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		case "ws":
+			HandleWebsocket(session, ttyClientOptions{
+				ReadOnly: !share.Write,
+				Resize:   true,
+				Expires:  share.Expires,
+			})(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func NewCmdTTYShare() *cobra.Command {
+	var flags struct {
+		Write   bool
+		Expires time.Duration
+	}
+
+	cmd := &cobra.Command{
+		Use:   "share <id>",
+		Short: "Share a terminal, and print the url of its live view",
+		Long: `Share a terminal, and print the url of a page showing it live.
+
+The view is read-only unless --write is set. The url stops working when it expires, or when the terminal is closed.
+It is served on localhost, and on the address set by the shareAddress setting if any.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res struct {
+				URL string `json:"url"`
+			}
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tty.share", map[string]any{
+				"id":      args[0],
+				"write":   flags.Write,
+				"expires": flags.Expires.String(),
+			}, &res); err != nil {
+				return fmt.Errorf("failed to share tty: %w", err)
+			}
+
+			fmt.Println(res.URL)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.Write, "write", false, "Let the viewers type in the terminal")
+	cmd.Flags().DurationVar(&flags.Expires, "expires", defaultShareExpiry, "Time after which the url stops working")
+
+	return cmd
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"embed"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed shell
+var shellFs embed.FS
+
+func NewCmdShellInit() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "shell-init <shell>",
+		Short: "Print the shell integration script",
+		Long: `Print the shell integration script, which reports the working directory, the title and the
+boundaries of commands to tweety, and sets up the completions of the tweety cli.
+
+Add one of the following lines to the config of your shell:
+
+  eval "$(tweety shell-init bash)"    # ~/.bashrc
+  eval "$(tweety shell-init zsh)"     # ~/.zshrc
+  tweety shell-init fish | source     # ~/.config/fish/config.fish
+
+Outside of tweety terminals, the script only sets up completions.`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"bash", "zsh", "fish"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			script, err := shellFs.ReadFile(fmt.Sprintf("shell/tweety.%s", args[0]))
+			if err != nil {
+				return fmt.Errorf("unsupported shell: %s", args[0])
+			}
+
+			os.Stdout.Write(script)
+
+			root := cmd.Root()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(os.Stdout, true)
+			case "zsh":
+				return root.GenZshCompletion(os.Stdout)
+			default:
+				return root.GenFishCompletion(os.Stdout, true)
+			}
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,61 @@
+# tweety shell integration for bash, load it from ~/.bashrc with:
+#   eval "$(tweety shell-init bash)"
+
+if [[ -n "$TWEETY_TTY" && -z "$__tweety_loaded" ]]; then
+    __tweety_loaded=1
+    __tweety_at_prompt=0
+
+    __tweety_urlencode() {
+        local LC_ALL=C i c
+        for ((i = 0; i < ${#1}; i++)); do
+            c="${1:i:1}"
+            case "$c" in
+                [a-zA-Z0-9/._~-]) printf '%s' "$c" ;;
+                *) printf '%%%02X' "'$c" ;;
+            esac
+        done
+    }
+
+    __tweety_precmd() {
+        local exit_status=$?
+        if [[ "$__tweety_at_prompt" == 0 && -n "$__tweety_started" ]]; then
+            # OSC 133 D: command finished
+            printf '\e]133;D;%s\a' "$exit_status"
+        fi
+        __tweety_started=1
+        __tweety_at_prompt=1
+        __tweety_in_prompt_command=1
+        # OSC 7: working directory
+        printf '\e]7;file://%s%s\a' "$HOSTNAME" "$(__tweety_urlencode "$PWD")"
+        # OSC 2: title
+        printf '\e]2;%s\a' "${PWD/#$HOME/\~}"
+        # OSC 133 A: prompt start
+        printf '\e]133;A\a'
+    }
+
+    # runs last in PROMPT_COMMAND, the commands run before it are part of the prompt
+    __tweety_prompt_done() {
+        __tweety_in_prompt_command=0
+    }
+
+    __tweety_preexec() {
+        # the DEBUG trap runs before every simple command, only the first one after the prompt starts the command line
+        if [[ "$__tweety_at_prompt" == 0 || "$__tweety_in_prompt_command" == 1 || -n "$COMP_LINE" || "$BASH_COMMAND" == __tweety_precmd* ]]; then
+            return
+        fi
+        __tweety_at_prompt=0
+        printf '\e]2;%s\a' "$BASH_COMMAND"
+        # OSC 133 C: command output start
+        printf '\e]133;C\a'
+    }
+
+    # PROMPT_COMMAND can be an array since bash 5.1
+    if [[ "$(declare -p PROMPT_COMMAND 2>/dev/null)" == "declare -a"* ]]; then
+        PROMPT_COMMAND=(__tweety_precmd "${PROMPT_COMMAND[@]}" __tweety_prompt_done)
+    else
+        PROMPT_COMMAND="__tweety_precmd${PROMPT_COMMAND:+; $PROMPT_COMMAND}; __tweety_prompt_done"
+    fi
+    # OSC 133 B: prompt end
+    PS1="$PS1\[\e]133;B\a\]"
+    trap '__tweety_preexec' DEBUG
+fi
@@ -0,0 +1,26 @@
+# tweety shell integration for fish, load it from ~/.config/fish/config.fish with:
+#   tweety shell-init fish | source
+
+if set -q TWEETY_TTY; and not set -q __tweety_loaded
+    set -g __tweety_loaded 1
+
+    function __tweety_prompt --on-event fish_prompt
+        # OSC 7: working directory
+        printf '\e]7;file://%s%s\a' (hostname) (string escape --style=url -- $PWD)
+        # OSC 2: title
+        printf '\e]2;%s\a' (prompt_pwd)
+        # OSC 133 A: prompt start
+        printf '\e]133;A\a'
+    end
+
+    function __tweety_preexec --on-event fish_preexec
+        printf '\e]2;%s\a' "$argv"
+        # OSC 133 C: command output start
+        printf '\e]133;C\a'
+    end
+
+    function __tweety_postexec --on-event fish_postexec
+        # OSC 133 D: command finished
+        printf '\e]133;D;%s\a' $status
+    end
+end
@@ -3,13 +3,19 @@ package cmd
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/cli/cli/v2/pkg/jsoncolor"
 	"github.com/mattn/go-isatty"
 	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/pomdtr/tweety/internal/markdown"
 	"github.com/spf13/cobra"
 )
 
@@ -39,7 +45,12 @@ func NewCmdTabs() *cobra.Command {
 		NewCmdTabsGoForward(),
 		NewCmdTabsGoBack(),
 		NewCmdTabsCaptureVisibleTab(),
+		NewCmdTabsScreenshot(),
+		NewCmdTabsPDF(),
 		NewCmdTabsPrint(),
+		NewCmdTabsEval(),
+		NewCmdTabsGroup(),
+		NewCmdTabsUngroup(),
 	)
 
 	return cmd
@@ -104,6 +115,8 @@ func NewCmdTabsCreate() *cobra.Command {
 		URL    string
 		Pinned bool
 		Active bool
+		Here   bool
+		Cwd    string
 	}
 
 	cmd := &cobra.Command{
@@ -117,7 +130,19 @@ func NewCmdTabsCreate() *cobra.Command {
 			if cmd.Flags().Changed("url") {
 				options["url"] = flags.URL
 			} else {
-				options["url"] = "/terminal.html"
+				cwd := flags.Cwd
+				if flags.Here {
+					var err error
+					if cwd, err = os.Getwd(); err != nil {
+						return fmt.Errorf("failed to get working directory: %w", err)
+					}
+				}
+
+				terminalURL, err := terminalPageURL(cwd)
+				if err != nil {
+					return err
+				}
+				options["url"] = terminalURL
 			}
 
 			if cmd.Flags().Changed("pinned") {
@@ -146,10 +171,32 @@ func NewCmdTabsCreate() *cobra.Command {
 	cmd.Flags().StringVar(&flags.URL, "url", "", "URL to open in the new tab")
 	cmd.Flags().BoolVar(&flags.Pinned, "pinned", false, "Pin the new tab")
 	cmd.Flags().BoolVar(&flags.Active, "active", false, "Activate the new tab")
+	cmd.Flags().BoolVar(&flags.Here, "here", false, "Start the terminal in the current working directory")
+	cmd.Flags().StringVar(&flags.Cwd, "cwd", "", "Start the terminal in this directory")
+	cmd.MarkFlagsMutuallyExclusive("url", "here", "cwd")
+	cmd.MarkFlagDirname("cwd")
 
 	return cmd
 }
 
+// terminalPageURL returns the URL of a terminal tab, starting in cwd if it is not empty.
+func terminalPageURL(cwd string) (string, error) {
+	if cwd == "" {
+		return "/terminal.html", nil
+	}
+
+	cwd, err := filepath.Abs(cwd)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	if stat, err := os.Stat(cwd); err != nil || !stat.IsDir() {
+		return "", fmt.Errorf("not a directory: %s", cwd)
+	}
+
+	return "/terminal.html?" + url.Values{"cwd": []string{cwd}}.Encode(), nil
+}
+
 func NewCmdTabsRemove() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "remove <tabID> [<tabID>...]",
@@ -343,12 +390,14 @@ func NewCmdTabsDiscard() *cobra.Command {
 
 func NewCmdTabsCaptureVisibleTab() *cobra.Command {
 	var options struct {
-		Format string `json:"format"`
+		Format  string `json:"format,omitempty"`
+		Quality int    `json:"quality,omitempty"`
 	}
 
 	cmd := &cobra.Command{
 		Use:   "capture-visible-tab [window-id]",
-		Short: "Capture the visible area of a tab",
+		Short: "Capture the visible area of a tab as a data URL",
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var params []any
 			if len(args) > 0 {
@@ -382,6 +431,9 @@ func NewCmdTabsCaptureVisibleTab() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().StringVar(&options.Format, "format", "", "Image format (png, jpeg)")
+	cmd.Flags().IntVar(&options.Quality, "quality", 0, "Quality of the jpeg image, between 0 and 100")
+
 	return cmd
 }
 
@@ -468,31 +520,317 @@ func NewCmdTabsGoBack() *cobra.Command {
 }
 
 func NewCmdTabsPrint() *cobra.Command {
+	var flags struct {
+		Format   string
+		Selector string
+	}
+
 	cmd := &cobra.Command{
 		Use:   "print [tabID]",
-		Short: "Print the HTML content of a tab",
+		Short: "Print the content of a tab",
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			params := []any{}
+			target := map[string]any{}
 			if len(args) > 0 {
 				tabID, err := strconv.Atoi(args[0])
 				if err != nil {
 					return fmt.Errorf("invalid tab ID: %w", err)
 				}
-				params = append(params, tabID)
+				target["tabId"] = tabID
+			}
+
+			extractFormat := "html"
+			switch flags.Format {
+			case "html", "markdown", "readability":
+			case "text":
+				extractFormat = "text"
+			default:
+				return fmt.Errorf("invalid format: %s", flags.Format)
+			}
+
+			var selector any
+			if flags.Selector != "" {
+				selector = flags.Selector
+			}
+
+			var results []struct {
+				Result struct {
+					URL      string   `json:"url"`
+					Contents []string `json:"contents"`
+				} `json:"result"`
+			}
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "scripting.executeScript", []any{map[string]any{
+				"target": target,
+				"func":   "extractContent",
+				"args":   []any{selector, extractFormat},
+			}}, &results); err != nil {
+				return fmt.Errorf("failed to get tab content: %w", err)
+			}
+
+			if len(results) == 0 {
+				return fmt.Errorf("no result returned from tab")
+			}
+
+			page := results[0].Result
+			if len(page.Contents) == 0 {
+				return fmt.Errorf("no element matches selector: %s", flags.Selector)
 			}
 
-			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "tabs.print", params)
+			for i, content := range page.Contents {
+				switch flags.Format {
+				case "markdown":
+					res, err := markdown.Convert(strings.NewReader(content), page.URL)
+					if err != nil {
+						return err
+					}
+					content = res
+				case "readability":
+					res, err := markdown.Readable(strings.NewReader(content), page.URL)
+					if err != nil {
+						return err
+					}
+					content = res
+				}
+
+				if i > 0 {
+					os.Stdout.WriteString("\n")
+				}
+
+				os.Stdout.WriteString(content)
+				if !strings.HasSuffix(content, "\n") {
+					os.Stdout.WriteString("\n")
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Format, "format", "f", "html", "Output format (html, text, markdown, readability)")
+	cmd.Flags().StringVarP(&flags.Selector, "selector", "s", "", "CSS selector of the elements to extract")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"html", "text", "markdown", "readability"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func NewCmdTabsEval() *cobra.Command {
+	var flags struct {
+		File      string
+		World     string
+		AllFrames bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "eval [tabID] [expression]",
+		Short: "Evaluate JavaScript in a tab and print the result as JSON",
+		Long: `Evaluate JavaScript in a tab and print the result as JSON.
+
+The script is injected in the tab, the csp of the page must allow eval in the main world. Otherwise, and for the
+isolated world, chromium browsers evaluate it through the debugger protocol: the browser then shows a "started
+debugging this browser" infobar, and the evaluation fails if devtools are open on the tab.
+A returned promise is awaited, it must settle within 5 seconds, the time the host waits for the browser to respond.`,
+		Args: cobra.RangeArgs(0, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var code string
+			if cmd.Flags().Changed("file") {
+				if len(args) > 1 {
+					return fmt.Errorf("expression and --file are mutually exclusive")
+				}
+
+				var content []byte
+				var err error
+				if flags.File == "-" {
+					content, err = io.ReadAll(os.Stdin)
+				} else {
+					content, err = os.ReadFile(flags.File)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to read script: %w", err)
+				}
+
+				code = string(content)
+			} else {
+				if len(args) == 0 {
+					return fmt.Errorf("an expression or --file is required")
+				}
+
+				code = args[len(args)-1]
+				args = args[:len(args)-1]
+			}
+
+			options := map[string]any{
+				"code":      code,
+				"allFrames": flags.AllFrames,
+			}
+			if len(args) > 0 {
+				tabID, err := strconv.Atoi(args[0])
+				if err != nil {
+					return fmt.Errorf("invalid tab ID: %w", err)
+				}
+				options["tabId"] = tabID
+			}
+
+			var world string
+			switch flags.World {
+			case "main":
+				world = "MAIN"
+			case "isolated":
+				world = "ISOLATED"
+			default:
+				return fmt.Errorf("invalid world: %s", flags.World)
+			}
+			options["world"] = world
+
+			var results []struct {
+				FrameID json.RawMessage `json:"frameId"`
+				Result  struct {
+					Value json.RawMessage `json:"value"`
+				} `json:"result"`
+			}
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tabs.evaluate", []any{options}, &results); err != nil {
+				var rpcErr *jsonrpc.JSONRPCError
+				if errors.As(err, &rpcErr) {
+					var exception struct {
+						Stack string `json:"stack"`
+					}
+					if json.Unmarshal(rpcErr.Data, &exception) == nil && exception.Stack != "" {
+						return fmt.Errorf("%s", exception.Stack)
+					}
+				}
+
+				return fmt.Errorf("failed to evaluate script: %w", err)
+			}
+
+			if len(results) == 0 {
+				return fmt.Errorf("no result returned from tab")
+			}
+
+			var output []byte
+			if flags.AllFrames {
+				type frameResult struct {
+					FrameID json.RawMessage `json:"frameId"`
+					Value   json.RawMessage `json:"value"`
+				}
+
+				var frames []frameResult
+				for _, res := range results {
+					frames = append(frames, frameResult{FrameID: res.FrameID, Value: res.Result.Value})
+				}
+
+				var err error
+				output, err = json.Marshal(frames)
+				if err != nil {
+					return fmt.Errorf("failed to marshal results: %w", err)
+				}
+			} else {
+				output = results[0].Result.Value
+				if len(output) == 0 {
+					output = []byte("null")
+				}
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(output)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(output), "  ")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.File, "file", "f", "", "Read the script from a file (- for stdin)")
+	cmd.Flags().StringVar(&flags.World, "world", "main", "JavaScript world to run the script in (main, isolated)")
+	cmd.Flags().BoolVar(&flags.AllFrames, "all-frames", false, "Run the script in every frame of the tab")
+	cmd.RegisterFlagCompletionFunc("world", cobra.FixedCompletions([]string{"main", "isolated"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func NewCmdTabsGroup() *cobra.Command {
+	var flags struct {
+		GroupID  int
+		WindowID int
+	}
+
+	cmd := &cobra.Command{
+		Use:   "group <tabID> [<tabID>...]",
+		Short: "Add tabs to a group, creating a new group if needed",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var tabIds []int
+			for _, arg := range args {
+				tabID, err := strconv.Atoi(arg)
+				if err != nil {
+					return fmt.Errorf("invalid tab ID '%s': %w", arg, err)
+				}
+
+				tabIds = append(tabIds, tabID)
+			}
+
+			options := map[string]any{
+				"tabIds": tabIds,
+			}
+
+			if cmd.Flags().Changed("group-id") {
+				options["groupId"] = flags.GroupID
+			}
+
+			if cmd.Flags().Changed("window-id") {
+				options["createProperties"] = map[string]any{
+					"windowId": flags.WindowID,
+				}
+			}
+
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "tabs.group", []any{options})
 			if err != nil {
-				return fmt.Errorf("failed to print tab content: %w", err)
+				return fmt.Errorf("failed to group tabs: %w", err)
 			}
 
-			var res string
-			if err := json.Unmarshal(resp.Result, &res); err != nil {
-				return fmt.Errorf("failed to parse print result: %w", err)
+			if resp.Error != nil {
+				os.Stderr.Write(resp.Error)
+				os.Exit(1)
+			}
+
+			os.Stdout.Write(resp.Result)
+			os.Stdout.WriteString("\n")
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&flags.GroupID, "group-id", 0, "ID of the group to add the tabs to")
+	cmd.Flags().IntVar(&flags.WindowID, "window-id", 0, "Window in which to create the new group")
+	cmd.MarkFlagsMutuallyExclusive("group-id", "window-id")
+
+	return cmd
+}
+
+func NewCmdTabsUngroup() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ungroup <tabID> [<tabID>...]",
+		Short: "Remove tabs from their group",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var tabIds []int
+			for _, arg := range args {
+				tabID, err := strconv.Atoi(arg)
+				if err != nil {
+					return fmt.Errorf("invalid tab ID '%s': %w", arg, err)
+				}
+
+				tabIds = append(tabIds, tabID)
+			}
+
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "tabs.ungroup", []any{tabIds})
+			if err != nil {
+				return fmt.Errorf("failed to ungroup tabs: %w", err)
+			}
+
+			if resp.Error != nil {
+				os.Stderr.Write(resp.Error)
+				os.Exit(1)
 			}
 
-			os.Stdout.WriteString(res)
 			return nil
 		},
 	}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/cli/cli/v2/pkg/jsoncolor"
+	"github.com/mattn/go-isatty"
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdTabGroups() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "tab-group",
+		Aliases: []string{"tab-groups"},
+		Short:   "Manage tab groups",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if env := os.Getenv("TWEETY_SOCKET"); env == "" {
+				return fmt.Errorf("TWEETY_SOCKET environment variable must be set")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.AddCommand(
+		NewCmdTabGroupsQuery(),
+		NewCmdTabGroupsGet(),
+		NewCmdTabGroupsUpdate(),
+		NewCmdTabGroupsMove(),
+	)
+
+	return cmd
+}
+
+func NewCmdTabGroupsQuery() *cobra.Command {
+	var flags struct {
+		Title     string
+		Color     string
+		Collapsed bool
+		WindowID  int
+	}
+
+	cmd := &cobra.Command{
+		Use:   "query",
+		Short: "List all tab groups",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options := make(map[string]any)
+			if cmd.Flags().Changed("title") {
+				options["title"] = flags.Title
+			}
+
+			if cmd.Flags().Changed("color") {
+				options["color"] = flags.Color
+			}
+
+			if cmd.Flags().Changed("collapsed") {
+				options["collapsed"] = flags.Collapsed
+			}
+
+			if cmd.Flags().Changed("window-id") {
+				options["windowId"] = flags.WindowID
+			}
+
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "tabGroups.query", []any{
+				options,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list tab groups: %w", err)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(resp.Result)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Title, "title", "", "Filter groups by title pattern")
+	cmd.Flags().StringVar(&flags.Color, "color", "", "Filter groups by color")
+	cmd.Flags().BoolVar(&flags.Collapsed, "collapsed", false, "Filter collapsed groups")
+	cmd.Flags().IntVar(&flags.WindowID, "window-id", 0, "Filter groups in the given window")
+
+	return cmd
+}
+
+func NewCmdTabGroupsGet() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get <groupID>",
+		Short: "Get information about a specific tab group",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			groupID, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid group ID: %w", err)
+			}
+
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "tabGroups.get", []any{groupID})
+			if err != nil {
+				return fmt.Errorf("failed to get tab group: %w", err)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(resp.Result)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func NewCmdTabGroupsUpdate() *cobra.Command {
+	var flags struct {
+		Title     string
+		Color     string
+		Collapsed bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "update <groupID>",
+		Short: "Update properties of a tab group",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			groupID, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid group ID: %w", err)
+			}
+
+			options := make(map[string]any)
+			if cmd.Flags().Changed("title") {
+				options["title"] = flags.Title
+			}
+
+			if cmd.Flags().Changed("color") {
+				options["color"] = flags.Color
+			}
+
+			if cmd.Flags().Changed("collapsed") {
+				options["collapsed"] = flags.Collapsed
+			}
+
+			if len(options) == 0 {
+				return fmt.Errorf("at least one field must be provided to update")
+			}
+
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "tabGroups.update", []any{groupID, options})
+			if err != nil {
+				return fmt.Errorf("failed to update tab group: %w", err)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(resp.Result)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Title, "title", "", "Title of the group")
+	cmd.Flags().StringVar(&flags.Color, "color", "", "Color of the group (grey, blue, red, yellow, green, pink, purple, cyan, orange)")
+	cmd.Flags().BoolVar(&flags.Collapsed, "collapsed", false, "Collapse the group")
+
+	return cmd
+}
+
+func NewCmdTabGroupsMove() *cobra.Command {
+	var flags struct {
+		Index    int
+		WindowID int
+	}
+
+	cmd := &cobra.Command{
+		Use:   "move <groupID>",
+		Short: "Move a tab group within its window, or to a new window",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			groupID, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid group ID: %w", err)
+			}
+
+			options := map[string]any{
+				"index": flags.Index,
+			}
+
+			if cmd.Flags().Changed("window-id") {
+				options["windowId"] = flags.WindowID
+			}
+
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "tabGroups.move", []any{groupID, options})
+			if err != nil {
+				return fmt.Errorf("failed to move tab group: %w", err)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(resp.Result)
+				return nil
+			}
+
+			jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&flags.Index, "index", -1, "Position to move the group to (-1 for the end of the window)")
+	cmd.Flags().IntVar(&flags.WindowID, "window-id", 0, "Window to move the group to")
+
+	return cmd
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cli/cli/v2/pkg/jsoncolor"
+	"github.com/mattn/go-isatty"
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/spf13/cobra"
+)
+
+func NewCmdTerm() *cobra.Command {
+	var flags struct {
+		Title    string
+		Cwd      string
+		Env      []string
+		KeepOpen bool
+		Record   bool
+		Window   bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "term [flags] -- <command> [args...]",
+		Short: "Run a command in a new terminal tab",
+		Long: `Run a command in a new terminal tab, which is closed when the command exits.
+
+The command is resolved using the PATH of the current shell, and runs in the current working directory.`,
+		Args: cobra.MinimumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if env := os.Getenv("TWEETY_SOCKET"); env == "" {
+				return fmt.Errorf("TWEETY_SOCKET environment variable must be set")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			command, err := exec.LookPath(args[0])
+			if err != nil {
+				return fmt.Errorf("command not found: %s", args[0])
+			}
+
+			if command, err = filepath.Abs(command); err != nil {
+				return fmt.Errorf("failed to get absolute path: %w", err)
+			}
+
+			cwd := flags.Cwd
+			if cwd == "" {
+				if cwd, err = os.Getwd(); err != nil {
+					return fmt.Errorf("failed to get working directory: %w", err)
+				}
+			} else if cwd, err = filepath.Abs(cwd); err != nil {
+				return fmt.Errorf("failed to get absolute path: %w", err)
+			}
+
+			env := make(map[string]string)
+			for _, entry := range flags.Env {
+				key, value, ok := strings.Cut(entry, "=")
+				if !ok {
+					return fmt.Errorf("invalid env '%s': expected KEY=VALUE", entry)
+				}
+				env[key] = value
+			}
+
+			title := flags.Title
+			if title == "" {
+				title = strings.Join(args, " ")
+			}
+
+			return openLaunchTerminal(ttyLaunch{
+				Command:  command,
+				Args:     args[1:],
+				Env:      env,
+				Cwd:      cwd,
+				Title:    title,
+				KeepOpen: flags.KeepOpen,
+				Record:   flags.Record,
+			}, flags.Window)
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Title, "title", "", "Title of the tab, defaults to the command line")
+	cmd.Flags().StringVar(&flags.Cwd, "cwd", "", "Working directory of the command, defaults to the current one")
+	cmd.Flags().StringArrayVar(&flags.Env, "env", nil, "Set an environment variable, formatted as KEY=VALUE")
+	cmd.Flags().BoolVar(&flags.KeepOpen, "keep-open", false, "Keep the tab open once the command exits, until enter is pressed")
+	cmd.Flags().BoolVar(&flags.Record, "record", false, "Record the terminal session, see tweety rec")
+	cmd.Flags().BoolVar(&flags.Window, "window", false, "Open the terminal in a new window")
+	cmd.MarkFlagDirname("cwd")
+	cmd.Flags().SetInterspersed(false)
+
+	return cmd
+}
+
+// openLaunchTerminal registers a launch with the host, and opens the terminal page running it in a new tab or window.
+func openLaunchTerminal(launch ttyLaunch, window bool) error {
+	var res struct {
+		Nonce string `json:"nonce"`
+	}
+	if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tty.prepare", launch, &res); err != nil {
+		return fmt.Errorf("failed to prepare terminal: %w", err)
+	}
+
+	terminalURL := "/terminal.html?" + url.Values{
+		"mode":  []string{"exec"},
+		"nonce": []string{res.Nonce},
+	}.Encode()
+
+	return openTerminalPage(terminalURL, window)
+}
+
+// openTerminalPage opens a terminal page of the extension in a new tab, or a new window.
+func openTerminalPage(terminalURL string, window bool) error {
+	method, options := "tabs.create", map[string]any{"url": terminalURL, "active": true}
+	if window {
+		method, options = "windows.create", map[string]any{"url": terminalURL, "focused": true}
+	}
+
+	resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), method, []any{options})
+	if err != nil {
+		return fmt.Errorf("failed to open terminal: %w", err)
+	}
+
+	if resp.Error != nil {
+		os.Stderr.Write(resp.Error)
+		os.Exit(1)
+	}
+
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		os.Stdout.Write(resp.Result)
+		return nil
+	}
+
+	return jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+}
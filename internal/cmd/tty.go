@@ -0,0 +1,1035 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aymanbagabas/go-pty"
+	"github.com/cli/cli/v2/pkg/jsoncolor"
+	"github.com/mattn/go-isatty"
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/pomdtr/tweety/internal/osc"
+	"github.com/spf13/cobra"
+)
+
+// ttySession is a terminal created through tty.create.
+type ttySession struct {
+	ID string
+	// TabID is the tab of the terminal page, it is 0 for terminals outside of a tab (side panel, popup)
+	TabID int
+	Pty   pty.Pty
+	// LayoutID and PaneID are set for the terminals shown as a pane of a terminal page
+	LayoutID string
+	PaneID   string
+	// Command is the command line of the program running in the tty
+	Command []string
+	// Output keeps the last output of the tty
+	Output *scrollback
+	// Recorder is set when the tty is recorded
+	Recorder *recorder
+
+	mu sync.Mutex
+	// inputOffset is the offset of the output when input was last sent from the cli, tty.wait matches the output after it
+	inputOffset int64
+	// inputLines is the number of lines of the last input sent from the cli, which are echoed by the terminal
+	inputLines int
+	// cwd, title and exitCode are reported by the shell integration
+	cwd      string
+	title    string
+	exitCode *int
+	// commandStart is set while a command runs, according to the marks of the shell integration
+	commandStart time.Time
+	// badge is set when the tab has a badge to clear once the next command starts
+	badge bool
+	// cols and rows are the size of the terminal, the smallest of the sizes reported by the terminal pages
+	cols, rows int
+	sizes      map[string]ttySize
+	// clients receive the events of the tty, their channel is closed when the program exits
+	clients map[chan ttyEvent]struct{}
+	exited  bool
+	// pages is the number of terminal pages connected to the tty
+	pages int
+}
+
+type ttySize struct {
+	Cols int
+	Rows int
+}
+
+// ttyEvent is sent to the clients of a tty, either output or a resize of the terminal.
+type ttyEvent struct {
+	Output []byte
+	Cols   int
+	Rows   int
+}
+
+// ttyClientBuffer is the number of events queued for a client, clients too slow to keep up are disconnected with the
+// ttyCloseSlowClient close code
+const ttyClientBuffer = 256
+
+// readLoop reads the output of the tty until its program exits, and broadcasts it to the clients.
+// The output is also written to the scrollback and the recorder of the session.
+func (s *ttySession) readLoop(onSequence func(osc.Sequence)) {
+	defer func() {
+		s.mu.Lock()
+		s.exited = true
+		for client := range s.clients {
+			close(client)
+		}
+		s.clients = nil
+		s.mu.Unlock()
+
+		s.Output.Close()
+		if s.Recorder != nil {
+			s.Recorder.Close()
+		}
+	}()
+
+	var parser osc.Parser
+	for {
+		buffer := make([]byte, maxBufferSizeBytes)
+		readLength, err := s.Pty.Read(buffer)
+		if err != nil {
+			return
+		}
+
+		for _, seq := range parser.Feed(buffer[:readLength]) {
+			onSequence(seq)
+		}
+
+		if s.Recorder != nil {
+			s.Recorder.Write(buffer[:readLength])
+		}
+
+		// the scrollback is written with the session locked, so that attach doesn't miss or repeat output
+		s.mu.Lock()
+		s.Output.Write(buffer[:readLength])
+		s.broadcast(ttyEvent{Output: buffer[:readLength]})
+		s.mu.Unlock()
+	}
+}
+
+// broadcast sends an event to the clients, it must be called with the session locked.
+func (s *ttySession) broadcast(event ttyEvent) {
+	for client := range s.clients {
+		select {
+		case client <- event:
+		default:
+			delete(s.clients, client)
+			close(client)
+		}
+	}
+}
+
+// attach returns the output kept so far and the size of the terminal, with a channel receiving the next events.
+// The channel is closed when the program exits, or when detach is called.
+func (s *ttySession) attach() (backlog []byte, cols int, rows int, events <-chan ttyEvent, detach func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backlog, _, _ = s.Output.since(0)
+	client := make(chan ttyEvent, ttyClientBuffer)
+	if s.exited {
+		close(client)
+	} else {
+		if s.clients == nil {
+			s.clients = make(map[chan ttyEvent]struct{})
+		}
+		s.clients[client] = struct{}{}
+	}
+
+	return backlog, s.cols, s.rows, client, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, ok := s.clients[client]; ok {
+			delete(s.clients, client)
+			close(client)
+		}
+	}
+}
+
+// resize records the size of the terminal in a page, the tty takes the smallest size of its pages.
+func (s *ttySession) resize(client string, cols int, rows int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sizes == nil {
+		s.sizes = make(map[string]ttySize)
+	}
+	s.sizes[client] = ttySize{Cols: cols, Rows: rows}
+
+	return s.applySize()
+}
+
+// applySize resizes the tty to the smallest size of its pages, and notifies the clients.
+// It must be called with the session locked.
+func (s *ttySession) applySize() error {
+	if len(s.sizes) == 0 {
+		return nil
+	}
+
+	cols, rows := math.MaxInt, math.MaxInt
+	for _, size := range s.sizes {
+		cols, rows = min(cols, size.Cols), min(rows, size.Rows)
+	}
+
+	if cols == s.cols && rows == s.rows {
+		return nil
+	}
+
+	if err := s.Pty.Resize(cols, rows); err != nil {
+		return fmt.Errorf("failed to set size for tty: %w", err)
+	}
+
+	if s.Recorder != nil {
+		if err := s.Recorder.resize(cols, rows); err != nil {
+			return fmt.Errorf("failed to record resize: %w", err)
+		}
+	}
+
+	s.cols, s.rows = cols, rows
+	s.broadcast(ttyEvent{Cols: cols, Rows: rows})
+	return nil
+}
+
+// ttyDetachTimeout is the time a tty is kept once its last page is disconnected, so that a reloaded page can attach to it again
+const ttyDetachTimeout = 5 * time.Second
+
+// connectPage registers a terminal page connected to the tty.
+func (s *ttySession) connectPage() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pages++
+}
+
+// disconnectPage unregisters a terminal page, and reports whether the tty has no pages left.
+// The size of the page no longer constrains the size of the tty.
+func (s *ttySession) disconnectPage(client string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pages--
+	delete(s.sizes, client)
+	s.applySize()
+
+	return s.pages == 0
+}
+
+// idle reports whether no page is connected to the tty.
+func (s *ttySession) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pages == 0
+}
+
+func (s *ttySession) hasExited() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.exited
+}
+
+// ttyInfo is the state of a terminal exposed to the cli.
+type ttyInfo struct {
+	ID       string   `json:"id"`
+	TabID    int      `json:"tabId,omitempty"`
+	Command  []string `json:"command,omitempty"`
+	Cwd      string   `json:"cwd,omitempty"`
+	Title    string   `json:"title,omitempty"`
+	ExitCode *int     `json:"exitCode,omitempty"`
+	Running  bool     `json:"running"`
+	// Focused is set for the tty of the focused pane of a page, and for ttys outside of a layout
+	Focused bool `json:"focused"`
+	// Offset is the number of bytes of output written to the tty, the output written after it is captured with since
+	Offset int64 `json:"offset"`
+}
+
+func (s *ttySession) info() ttyInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return ttyInfo{
+		ID:       s.ID,
+		TabID:    s.TabID,
+		Command:  s.Command,
+		Cwd:      s.cwd,
+		Title:    s.title,
+		ExitCode: s.exitCode,
+		Running:  !s.commandStart.IsZero(),
+		Offset:   s.Output.offset(),
+	}
+}
+
+// setCwd parses the file url of an OSC 7 sequence, and records the working directory.
+func (s *ttySession) setCwd(data string) {
+	rest, ok := strings.CutPrefix(data, "file://")
+	if !ok {
+		return
+	}
+
+	// the hostname is ignored, the shell always runs on this host
+	_, path, ok := strings.Cut(rest, "/")
+	if !ok {
+		return
+	}
+
+	cwd, err := url.PathUnescape("/" + path)
+	if err != nil {
+		cwd = "/" + path
+	}
+
+	s.mu.Lock()
+	s.cwd = cwd
+	s.mu.Unlock()
+}
+
+func (s *ttySession) setTitle(title string) {
+	s.mu.Lock()
+	s.title = title
+	s.mu.Unlock()
+}
+
+// ttySpec is the program run in a tty, as requested by the terminal page.
+type ttySpec struct {
+	Mode    string            `json:"mode"`
+	App     string            `json:"app"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+	Cwd     string            `json:"cwd"`
+	Nonce   string            `json:"nonce"`
+	// TTY is the terminal to attach to for the attach mode, or else the focused terminal of the tab Tab
+	TTY string `json:"tty"`
+	Tab int    `json:"tab"`
+	// Record enables the recording of the tty, it is always enabled by the record setting
+	Record bool `json:"record"`
+	// launch is the command claimed with the nonce of the exec mode, it is kept to run it again in the same pane
+	launch *ttyLaunch
+}
+
+// ttyLaunchTimeout is the time the terminal page has to claim a launch
+const ttyLaunchTimeout = time.Minute
+
+// ttyLaunch is a command to run in a new terminal, registered by the cli through tty.prepare.
+// Terminal pages claim it with its nonce, so that they can't be used to run arbitrary commands.
+type ttyLaunch struct {
+	Command  string            `json:"command"`
+	Args     []string          `json:"args"`
+	Env      map[string]string `json:"env"`
+	Cwd      string            `json:"cwd"`
+	Title    string            `json:"title"`
+	KeepOpen bool              `json:"keepOpen"`
+	Record   bool              `json:"record"`
+	expires  time.Time
+}
+
+// ttyRegistry holds the terminals created by the host, until their websocket connection is closed,
+// and the layouts of the terminal pages, which outlive their terminals so that a reloaded page restores its panes.
+type ttyRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*ttySession
+	launches map[string]*ttyLaunch
+	layouts  map[string]*paneLayout
+	shares   map[string]*ttyShare
+}
+
+func newTTYRegistry() *ttyRegistry {
+	return &ttyRegistry{
+		sessions: make(map[string]*ttySession),
+		launches: make(map[string]*ttyLaunch),
+		layouts:  make(map[string]*paneLayout),
+		shares:   make(map[string]*ttyShare),
+	}
+}
+
+// prepareLaunch registers a launch, and returns its nonce.
+func (r *ttyRegistry) prepareLaunch(launch *ttyLaunch) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for nonce, l := range r.launches {
+		if time.Now().After(l.expires) {
+			delete(r.launches, nonce)
+		}
+	}
+
+	nonce := rand.Text()
+	launch.expires = time.Now().Add(ttyLaunchTimeout)
+	r.launches[nonce] = launch
+
+	return nonce
+}
+
+// takeLaunch returns the launch of a nonce, which can only be used once.
+func (r *ttyRegistry) takeLaunch(nonce string) (*ttyLaunch, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	launch, ok := r.launches[nonce]
+	if !ok {
+		return nil, false
+	}
+
+	delete(r.launches, nonce)
+	if time.Now().After(launch.expires) {
+		return nil, false
+	}
+
+	return launch, true
+}
+
+func (r *ttyRegistry) add(session *ttySession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[session.ID] = session
+}
+
+func (r *ttyRegistry) get(ttyID string) (*ttySession, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[ttyID]
+	return session, ok
+}
+
+func (r *ttyRegistry) list() []ttyInfo {
+	r.mu.Lock()
+	sessions := make([]*ttySession, 0, len(r.sessions))
+	for _, session := range r.sessions {
+		sessions = append(sessions, session)
+	}
+	r.mu.Unlock()
+
+	infos := make([]ttyInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, r.info(session))
+	}
+
+	return infos
+}
+
+// focusedTTY returns the tty of the focused pane of a tab.
+func (r *ttyRegistry) focusedTTY(tabID int) (*ttySession, bool) {
+	for _, info := range r.list() {
+		if info.TabID == tabID && info.Focused {
+			return r.get(info.ID)
+		}
+	}
+
+	return nil, false
+}
+
+// info returns the state of a tty, with the focus of its pane.
+func (r *ttyRegistry) info(session *ttySession) ttyInfo {
+	info := session.info()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info.Focused = true
+	if layout, ok := r.layouts[session.LayoutID]; ok {
+		pane := layout.Root.find(layout.Focus)
+		info.Focused = pane != nil && pane.TTY == session.ID
+	}
+
+	return info
+}
+
+func (r *ttyRegistry) remove(ttyID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[ttyID]
+	if !ok {
+		return
+	}
+	delete(r.sessions, ttyID)
+
+	for token, share := range r.shares {
+		if share.TTY == ttyID {
+			delete(r.shares, token)
+		}
+	}
+
+	// the pane keeps the working directory of its terminal, to restore it when the page is reloaded
+	if layout, ok := r.layouts[session.LayoutID]; ok {
+		if node := layout.Root.find(session.PaneID); node != nil && node.TTY == ttyID {
+			node.TTY = ""
+			if cwd := session.info().Cwd; cwd != "" {
+				node.Cwd = cwd
+			}
+		}
+	}
+
+	r.expireLayouts()
+}
+
+// handlePromptMark tracks the commands run in the terminal from the OSC 133 marks of the shell integration,
+// and notifies the user when a long-running command completes while its tab is in the background.
+func handlePromptMark(logger *slog.Logger, host *jsonrpc.Host, session *ttySession, data string) {
+	mark, params, _ := strings.Cut(data, ";")
+	switch mark {
+	case "C":
+		session.mu.Lock()
+		session.commandStart = time.Now()
+		clearBadge := session.badge
+		session.badge = false
+		session.mu.Unlock()
+
+		if clearBadge {
+			go func() {
+				if err := setTabBadge(host, session.TabID, "", ""); err != nil {
+					logger.Error("failed to clear tab badge", "tty", session.ID, "error", err)
+				}
+			}()
+		}
+	case "D":
+		// D;<exit status>, the exit status is omitted by some shells
+		exitCode, _ := strconv.Atoi(strings.SplitN(params, ";", 2)[0])
+
+		session.mu.Lock()
+		start := session.commandStart
+		session.commandStart = time.Time{}
+		session.exitCode = &exitCode
+		session.mu.Unlock()
+
+		threshold := k.Duration("notifyThreshold")
+		if start.IsZero() || threshold <= 0 || session.TabID == 0 {
+			return
+		}
+
+		duration := time.Since(start)
+		if duration < threshold {
+			return
+		}
+
+		go func() {
+			if err := notifyCommandCompletion(host, session, exitCode, duration); err != nil {
+				logger.Error("failed to notify command completion", "tty", session.ID, "error", err)
+			}
+		}()
+	}
+}
+
+func notifyCommandCompletion(host *jsonrpc.Host, session *ttySession, exitCode int, duration time.Duration) error {
+	var tab browserTab
+	if err := host.Call("tabs.get", []any{session.TabID}, &tab); err != nil {
+		return fmt.Errorf("failed to get tab: %w", err)
+	}
+
+	if tab.Active {
+		var window struct {
+			Focused bool `json:"focused"`
+		}
+		if err := host.Call("windows.get", []any{tab.WindowID}, &window); err != nil {
+			return fmt.Errorf("failed to get window: %w", err)
+		}
+
+		if window.Focused {
+			return nil
+		}
+	}
+
+	title := "Command completed"
+	badge, color := "✓", "#2e7d32"
+	if exitCode != 0 {
+		title = fmt.Sprintf("Command failed with exit code %d", exitCode)
+		badge, color = strconv.Itoa(exitCode), "#c62828"
+	}
+
+	session.mu.Lock()
+	session.badge = true
+	session.mu.Unlock()
+
+	if err := setTabBadge(host, session.TabID, badge, color); err != nil {
+		return err
+	}
+
+	return host.Call("notifications.create", []any{terminalNotificationID(session.TabID), map[string]any{
+		"type":    "basic",
+		"title":   title,
+		"message": fmt.Sprintf("%s after %s", tab.Title, duration.Round(time.Second)),
+		"iconUrl": "/icon/128.png",
+	}}, nil)
+}
+
+// setTabBadge sets the badge of the extension icon for a tab, an empty text clears it.
+func setTabBadge(host *jsonrpc.Host, tabID int, text string, color string) error {
+	if err := host.Call("action.setBadgeText", []any{map[string]any{"tabId": tabID, "text": text}}, nil); err != nil {
+		return fmt.Errorf("failed to set badge text: %w", err)
+	}
+
+	if color == "" {
+		return nil
+	}
+
+	if err := host.Call("action.setBadgeBackgroundColor", []any{map[string]any{"tabId": tabID, "color": color}}, nil); err != nil {
+		return fmt.Errorf("failed to set badge color: %w", err)
+	}
+
+	return nil
+}
+
+// terminalNotificationID returns the ID of a notification focusing the tab when clicked.
+func terminalNotificationID(tabID int) string {
+	return fmt.Sprintf("%s%d:%s", notifyNotificationPrefix, tabID, rand.Text())
+}
+
+// ttyScrollbackSize is the number of bytes of output kept for each tty
+const ttyScrollbackSize = 256 * 1024
+
+// scrollback keeps the last output of a tty, and wakes up the requests waiting for new output.
+type scrollback struct {
+	mu   sync.Mutex
+	data []byte
+	// written is the number of bytes written since the tty was created
+	written int64
+	closed  bool
+	changed chan struct{}
+}
+
+func newScrollback() *scrollback {
+	return &scrollback{changed: make(chan struct{})}
+}
+
+func (b *scrollback) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.data = append(b.data, p...)
+	// the buffer is trimmed once it doubles, to avoid copying it on each write
+	if len(b.data) > 2*ttyScrollbackSize {
+		b.data = slices.Clone(b.data[len(b.data)-ttyScrollbackSize:])
+	}
+	b.written += int64(len(p))
+
+	close(b.changed)
+	b.changed = make(chan struct{})
+
+	return len(p), nil
+}
+
+// Close marks the end of the output, when the program of the tty exits.
+func (b *scrollback) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		close(b.changed)
+	}
+
+	return nil
+}
+
+func (b *scrollback) offset() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.written
+}
+
+// since returns the output written after an offset, as far as it is kept, a channel closed on the next write,
+// and whether the output is closed.
+func (b *scrollback) since(offset int64) ([]byte, <-chan struct{}, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := max(int64(len(b.data))-(b.written-offset), 0)
+	return slices.Clone(b.data[start:]), b.changed, b.closed
+}
+
+// inputLines returns the number of lines submitted by an input, a line ends with \n, \r or \r\n.
+func inputLines(data string) int {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	return strings.Count(data, "\n") + strings.Count(data, "\r")
+}
+
+// skipLines returns the text after its first n lines, or an empty string if it has fewer lines.
+func skipLines(text string, n int) string {
+	for range n {
+		_, rest, ok := strings.Cut(text, "\n")
+		if !ok {
+			return ""
+		}
+		text = rest
+	}
+
+	return text
+}
+
+// plainText strips the escape sequences of terminal output, and applies carriage returns and backspaces.
+func plainText(data []byte) string {
+	var text []byte
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == 0x1b && i+1 < len(data):
+			i++
+			switch data[i] {
+			case '[':
+				// CSI, ends with a byte in the 0x40-0x7e range
+				for i+1 < len(data) && (data[i+1] < 0x40 || data[i+1] > 0x7e) {
+					i++
+				}
+				i++
+			case ']', 'P', '_', '^':
+				// OSC and other strings, end with BEL or ST
+				for i+1 < len(data) && data[i+1] != 0x07 && !(data[i+1] == 0x1b && i+2 < len(data) && data[i+2] == '\\') {
+					i++
+				}
+				if i+1 < len(data) && data[i+1] == 0x1b {
+					i++
+				}
+				i++
+			case '(', ')', '*', '+', '#', '%':
+				// character set designations have a parameter
+				i++
+			}
+		case c == '\r':
+			if i+1 < len(data) && data[i+1] == '\n' {
+				continue
+			}
+			// the next output overwrites the line
+			text = text[:bytes.LastIndexByte(text, '\n')+1]
+		case c == '\b':
+			if len(text) > 0 && text[len(text)-1] != '\n' {
+				text = text[:len(text)-1]
+			}
+		case c < 0x20 && c != '\n' && c != '\t', c == 0x7f:
+			// other control characters are not printed
+		default:
+			text = append(text, c)
+		}
+	}
+
+	return string(text)
+}
+
+// ttyWaitTimeout must stay below the timeout of the cli http client, the cli polls until the pattern matches
+var ttyWaitTimeout = 5 * time.Second
+
+// handleTTYWait blocks until the output of a tty since input was last sent from the cli matches a pattern,
+// or the timeout expires.
+func handleTTYWait(ttys *ttyRegistry) jsonrpc.RequestHandlerFunc {
+	return func(input []byte) (any, error) {
+		var params struct {
+			ID      string `json:"id"`
+			Pattern string `json:"pattern"`
+			Timeout int    `json:"timeout"`
+			// IncludeEcho matches the echo of the input too, which is skipped by default
+			IncludeEcho bool `json:"includeEcho"`
+		}
+
+		if err := json.Unmarshal(input, &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tty.wait params: %w", err)
+		}
+
+		pattern, err := regexp.Compile(params.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+
+		session, ok := ttys.get(params.ID)
+		if !ok {
+			return nil, fmt.Errorf("invalid tty ID: %s", params.ID)
+		}
+
+		session.mu.Lock()
+		offset := session.inputOffset
+		echoLines := session.inputLines
+		session.mu.Unlock()
+
+		if params.IncludeEcho {
+			echoLines = 0
+		}
+
+		timeout := time.After(time.Duration(params.Timeout) * time.Millisecond)
+		for {
+			data, changed, closed := session.Output.since(offset)
+			text := skipLines(plainText(data), echoLines)
+			if loc := pattern.FindStringIndex(text); loc != nil {
+				return map[string]any{"matched": true, "match": text[loc[0]:loc[1]]}, nil
+			}
+
+			if closed {
+				return nil, fmt.Errorf("tty %s exited", params.ID)
+			}
+
+			select {
+			case <-changed:
+			case <-timeout:
+				return map[string]any{"matched": false}, nil
+			}
+		}
+	}
+}
+
+func NewCmdTTY() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tty",
+		Short: "Interact with the terminals open in the browser",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if env := os.Getenv("TWEETY_SOCKET"); env == "" {
+				return fmt.Errorf("TWEETY_SOCKET environment variable must be set")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.AddCommand(
+		NewCmdTTYList(),
+		NewCmdTTYAttach(),
+		NewCmdTTYSend(),
+		NewCmdTTYCapture(),
+		NewCmdTTYWait(),
+		NewCmdTTYShare(),
+	)
+
+	return cmd
+}
+
+func NewCmdTTYList() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the running terminals",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resp, err := jsonrpc.SendRequest(os.Getenv("TWEETY_SOCKET"), "tty.list", map[string]any{})
+			if err != nil {
+				return fmt.Errorf("failed to list ttys: %w", err)
+			}
+
+			if resp.Error != nil {
+				os.Stderr.Write(resp.Error)
+				os.Exit(1)
+			}
+
+			if !isatty.IsTerminal(os.Stdout.Fd()) {
+				os.Stdout.Write(resp.Result)
+				return nil
+			}
+
+			return jsoncolor.Write(os.Stdout, bytes.NewReader(resp.Result), "  ")
+		},
+	}
+
+	return cmd
+}
+
+func NewCmdTTYAttach() *cobra.Command {
+	var flags struct {
+		Window bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "attach <id>",
+		Short: "Open a running terminal in a new tab",
+		Long: `Open a running terminal in a new tab, or a new window with --window.
+
+The terminal stays open in the pages already showing it, and takes the size of the smallest of them.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			terminalURL := "/terminal.html?" + url.Values{
+				"tty": []string{args[0]},
+			}.Encode()
+
+			return openTerminalPage(terminalURL, flags.Window)
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.Window, "window", false, "Open the terminal in a new window")
+
+	return cmd
+}
+
+func NewCmdTTYSend() *cobra.Command {
+	var flags struct {
+		Raw bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "send <id> [input]",
+		Short: "Send input to a terminal, reading it from stdin if omitted",
+		Long: `Send input to the program running in a terminal, as if it was typed.
+
+Escape sequences in the input are interpreted (\n, \r, \t, \e, \xHH, \\), unless --raw is set.`,
+		Example: `  tweety tty send 1a2b3c 'make test\n'`,
+		Args:    cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var data string
+			if len(args) > 1 {
+				data = args[1]
+				if !flags.Raw {
+					data = unescapeInput(data)
+				}
+			} else {
+				content, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("failed to read stdin: %w", err)
+				}
+				data = string(content)
+			}
+
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tty.write", map[string]any{
+				"id":   args[0],
+				"data": data,
+			}, nil); err != nil {
+				return fmt.Errorf("failed to send input: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.Raw, "raw", false, "Send the input as is, without interpreting escape sequences")
+
+	return cmd
+}
+
+// unescapeInput interprets the escape sequences of Go strings, and \e for the escape key.
+// Invalid sequences are kept as is.
+func unescapeInput(input string) string {
+	var output strings.Builder
+	for len(input) > 0 {
+		if strings.HasPrefix(input, `\e`) {
+			output.WriteByte(0x1b)
+			input = input[2:]
+			continue
+		}
+
+		value, multibyte, tail, err := strconv.UnquoteChar(input, 0)
+		if err != nil {
+			output.WriteByte(input[0])
+			input = input[1:]
+			continue
+		}
+
+		if multibyte {
+			output.WriteRune(value)
+		} else {
+			output.WriteByte(byte(value))
+		}
+		input = tail
+	}
+
+	return output.String()
+}
+
+func NewCmdTTYCapture() *cobra.Command {
+	var flags struct {
+		Lines int
+		Raw   bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "capture <id>",
+		Short: "Print the recent output of a terminal",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var res struct {
+				Output string `json:"output"`
+			}
+			if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tty.capture", map[string]any{
+				"id":    args[0],
+				"lines": flags.Lines,
+				"raw":   flags.Raw,
+			}, &res); err != nil {
+				return fmt.Errorf("failed to capture output: %w", err)
+			}
+
+			os.Stdout.WriteString(res.Output)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&flags.Lines, "lines", 0, "Number of lines to print, defaults to the whole scrollback")
+	cmd.Flags().BoolVar(&flags.Raw, "raw", false, "Keep the escape sequences of the output")
+
+	return cmd
+}
+
+func NewCmdTTYWait() *cobra.Command {
+	var flags struct {
+		Pattern     string
+		Timeout     time.Duration
+		IncludeEcho bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "wait <id> --pattern <regexp>",
+		Short: "Wait until the output of a terminal matches a pattern, and print the match",
+		Long: `Wait until the output of a terminal matches a regular expression, and print the match.
+
+The output is matched from the last input sent with tweety tty send, or from the start of the scrollback,
+so that the output of a command can't be missed when waiting right after sending it.
+The lines echoed by the terminal for the input are skipped, unless --include-echo is set: programs which
+don't echo their input, such as password prompts, need it not to skip their output.`,
+		Example: `  tweety tty send 1a2b3c 'make test\n' && tweety tty wait 1a2b3c --pattern 'PASS|FAIL'`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := regexp.Compile(flags.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern: %w", err)
+			}
+
+			var deadline time.Time
+			if flags.Timeout > 0 {
+				deadline = time.Now().Add(flags.Timeout)
+			}
+
+			for {
+				timeout := ttyWaitTimeout
+				if !deadline.IsZero() {
+					timeout = min(timeout, time.Until(deadline))
+				}
+
+				var res struct {
+					Matched bool   `json:"matched"`
+					Match   string `json:"match"`
+				}
+				if err := jsonrpc.Call(os.Getenv("TWEETY_SOCKET"), "tty.wait", map[string]any{
+					"id":          args[0],
+					"pattern":     flags.Pattern,
+					"timeout":     timeout.Milliseconds(),
+					"includeEcho": flags.IncludeEcho,
+				}, &res); err != nil {
+					return fmt.Errorf("failed to wait for output: %w", err)
+				}
+
+				if res.Matched {
+					fmt.Println(res.Match)
+					return nil
+				}
+
+				if !deadline.IsZero() && time.Now().After(deadline) {
+					return fmt.Errorf("timed out waiting for %s", flags.Pattern)
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Pattern, "pattern", "", "Regular expression to match")
+	cmd.Flags().DurationVar(&flags.Timeout, "timeout", 0, "Maximum time to wait, no limit by default")
+	cmd.Flags().BoolVar(&flags.IncludeEcho, "include-echo", false, "Match the echo of the last input too")
+	cmd.MarkFlagRequired("pattern")
+
+	return cmd
+}
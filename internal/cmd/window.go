@@ -137,6 +137,7 @@ func NewCmdWindowsCreate() *cobra.Command {
 		windowType string
 		width      int
 		height     int
+		cwd        string
 	}
 
 	cmd := &cobra.Command{
@@ -147,6 +148,12 @@ func NewCmdWindowsCreate() *cobra.Command {
 
 			if flags.url != "" {
 				options["url"] = flags.url
+			} else if flags.cwd != "" {
+				terminalURL, err := terminalPageURL(flags.cwd)
+				if err != nil {
+					return err
+				}
+				options["url"] = terminalURL
 			}
 
 			if cmd.Flags().Changed("focused") {
@@ -190,6 +197,9 @@ func NewCmdWindowsCreate() *cobra.Command {
 	cmd.Flags().StringVar(&flags.windowType, "type", "", "Window type (normal, popup, panel)")
 	cmd.Flags().IntVar(&flags.width, "width", 0, "Window width")
 	cmd.Flags().IntVar(&flags.height, "height", 0, "Window height")
+	cmd.Flags().StringVar(&flags.cwd, "cwd", "", "Open a terminal starting in this directory")
+	cmd.MarkFlagsMutuallyExclusive("url", "cwd")
+	cmd.MarkFlagDirname("cwd")
 
 	return cmd
 }
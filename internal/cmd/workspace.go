@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/knadh/koanf/v2"
+	"github.com/pomdtr/tweety/internal/jsonrpc"
+	"github.com/spf13/cobra"
+)
+
+// Workspace describes a set of windows and tabs, as declared in the workspaces section of the config.
+type Workspace struct {
+	Windows []WorkspaceWindow `json:"windows"`
+}
+
+type WorkspaceWindow struct {
+	Tabs []WorkspaceTab `json:"tabs"`
+}
+
+type WorkspaceTab struct {
+	URL    string   `json:"url"`
+	Match  string   `json:"match"`
+	App    string   `json:"app"`
+	Args   []string `json:"args"`
+	Pinned bool     `json:"pinned"`
+}
+
+func NewCmdWorkspace() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "workspace",
+		Aliases: []string{"workspaces"},
+		Short:   "Apply workspaces declared in the config",
+	}
+
+	cmd.AddCommand(
+		NewCmdWorkspaceList(),
+		NewCmdWorkspaceUp(),
+	)
+
+	return cmd
+}
+
+func NewCmdWorkspaceList() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List available workspaces",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			workspaces, err := loadWorkspaces()
+			if err != nil {
+				return err
+			}
+
+			var names []string
+			for name := range workspaces {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				fmt.Println(name)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func NewCmdWorkspaceUp() *cobra.Command {
+	var flags struct {
+		DryRun bool
+	}
+
+	cmd := &cobra.Command{
+		Use:   "up <name>",
+		Short: "Open the missing tabs of a workspace",
+		Long: `Open the missing tabs of a workspace.
+
+Tabs matching an entry of the workspace are reused, other tabs are left alone.
+A tab matches an entry if its URL matches the entry "match" glob pattern,
+or starts with the entry URL when no pattern is set.`,
+		Args: cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+
+			workspaces, err := loadWorkspaces()
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveError
+			}
+
+			var names []string
+			for name := range workspaces {
+				names = append(names, name)
+			}
+
+			return names, cobra.ShellCompDirectiveNoFileComp
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if env := os.Getenv("TWEETY_SOCKET"); env == "" {
+				return fmt.Errorf("TWEETY_SOCKET environment variable must be set")
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			workspaces, err := loadWorkspaces()
+			if err != nil {
+				return err
+			}
+
+			workspace, ok := workspaces[args[0]]
+			if !ok {
+				return fmt.Errorf("unknown workspace: %s", args[0])
+			}
+
+			return applyWorkspace(workspace, flags.DryRun)
+		},
+	}
+
+	cmd.Flags().BoolVar(&flags.DryRun, "dry-run", false, "Print the changes without applying them")
+
+	return cmd
+}
+
+func loadWorkspaces() (map[string]Workspace, error) {
+	workspaces := make(map[string]Workspace)
+	if err := k.UnmarshalWithConf("workspaces", &workspaces, koanf.UnmarshalConf{Tag: "json"}); err != nil {
+		return nil, fmt.Errorf("failed to parse workspaces: %w", err)
+	}
+
+	return workspaces, nil
+}
+
+func applyWorkspace(workspace Workspace, dryRun bool) error {
+	socketPath := os.Getenv("TWEETY_SOCKET")
+
+	var tabs []browserTab
+	if err := jsonrpc.Call(socketPath, "tabs.query", []any{map[string]any{}}, &tabs); err != nil {
+		return fmt.Errorf("failed to list tabs: %w", err)
+	}
+
+	// a tab can only satisfy a single entry
+	claimed := make(map[int]bool)
+	for _, window := range workspace.Windows {
+		var missing []WorkspaceTab
+		windowID := 0
+		for _, entry := range window.Tabs {
+			tab, ok, err := findWorkspaceTab(entry, tabs, claimed)
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				missing = append(missing, entry)
+				continue
+			}
+
+			claimed[tab.ID] = true
+			if windowID == 0 {
+				windowID = tab.WindowID
+			}
+
+			if tab.Pinned == entry.Pinned {
+				continue
+			}
+
+			fmt.Printf("~ %s (pinned: %t)\n", tab.URL, entry.Pinned)
+			if dryRun {
+				continue
+			}
+
+			if err := jsonrpc.Call(socketPath, "tabs.update", []any{tab.ID, map[string]any{"pinned": entry.Pinned}}, nil); err != nil {
+				return fmt.Errorf("failed to update tab %d: %w", tab.ID, err)
+			}
+		}
+
+		for _, entry := range missing {
+			entryURL := entry.tabURL()
+			fmt.Printf("+ %s\n", entryURL)
+			if dryRun {
+				continue
+			}
+
+			// the first missing tab of a window without any matching tab opens a new window
+			if windowID == 0 {
+				var created browserWindow
+				if err := jsonrpc.Call(socketPath, "windows.create", []any{map[string]any{"url": entryURL}}, &created); err != nil {
+					return fmt.Errorf("failed to create window: %w", err)
+				}
+
+				windowID = created.ID
+				if entry.Pinned {
+					var newTabs []browserTab
+					if err := jsonrpc.Call(socketPath, "tabs.query", []any{map[string]any{"windowId": windowID}}, &newTabs); err != nil {
+						return fmt.Errorf("failed to list tabs of window %d: %w", windowID, err)
+					}
+
+					for _, tab := range newTabs {
+						if err := jsonrpc.Call(socketPath, "tabs.update", []any{tab.ID, map[string]any{"pinned": true}}, nil); err != nil {
+							return fmt.Errorf("failed to update tab %d: %w", tab.ID, err)
+						}
+					}
+				}
+
+				continue
+			}
+
+			if err := jsonrpc.Call(socketPath, "tabs.create", []any{map[string]any{
+				"windowId": windowID,
+				"url":      entryURL,
+				"pinned":   entry.Pinned,
+				"active":   false,
+			}}, nil); err != nil {
+				return fmt.Errorf("failed to create tab: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// tabURL returns the URL used to open the entry in a new tab.
+func (t WorkspaceTab) tabURL() string {
+	if t.App == "" {
+		return t.URL
+	}
+
+	appUrl := url.URL{
+		Path: "/terminal.html",
+		RawQuery: url.Values{
+			"mode": []string{"app"},
+			"app":  []string{t.App},
+			"arg":  t.Args,
+		}.Encode(),
+	}
+
+	return appUrl.String()
+}
+
+func findWorkspaceTab(entry WorkspaceTab, tabs []browserTab, claimed map[int]bool) (browserTab, bool, error) {
+	var pattern *regexp.Regexp
+	if entry.Match != "" {
+		var err error
+		pattern, err = globToRegexp(entry.Match)
+		if err != nil {
+			return browserTab{}, false, fmt.Errorf("invalid match pattern %q: %w", entry.Match, err)
+		}
+	}
+
+	for _, tab := range tabs {
+		if claimed[tab.ID] {
+			continue
+		}
+
+		tabURL := tab.URL
+		if tabURL == "" {
+			tabURL = tab.PendingURL
+		}
+
+		switch {
+		case pattern != nil:
+			if pattern.MatchString(tabURL) {
+				return tab, true, nil
+			}
+		case entry.App != "":
+			u, err := url.Parse(tabURL)
+			if err != nil || !strings.HasSuffix(u.Path, "/terminal.html") {
+				continue
+			}
+
+			query := u.Query()
+			if query.Get("mode") == "app" && query.Get("app") == entry.App {
+				return tab, true, nil
+			}
+		case entry.URL != "":
+			if strings.HasPrefix(tabURL, entry.URL) {
+				return tab, true, nil
+			}
+		}
+	}
+
+	return browserTab{}, false, nil
+}
+
+// globToRegexp converts a glob pattern, where * matches any sequence of characters, to an anchored regexp.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	parts := strings.Split(glob, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	return regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+}
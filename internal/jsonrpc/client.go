@@ -11,7 +11,15 @@ import (
 	"time"
 )
 
+// defaultClientTimeout is the time the host has to answer a request, it is longer than the time the extension has to
+// answer the requests forwarded by the host
+const defaultClientTimeout = 10 * time.Second
+
 func SendRequest(socketPath string, method string, params interface{}) (*JSONRPCResponse, error) {
+	return sendRequest(socketPath, method, params, defaultClientTimeout)
+}
+
+func sendRequest(socketPath string, method string, params interface{}, timeout time.Duration) (*JSONRPCResponse, error) {
 	paramsBytes, err := json.Marshal(params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal params: %w", err)
@@ -40,7 +48,7 @@ func SendRequest(socketPath string, method string, params interface{}) (*JSONRPC
 				return net.Dial("unix", socketPath)
 			},
 		},
-		Timeout: 10 * time.Second,
+		Timeout: timeout,
 	}
 
 	req, err := http.NewRequest("POST", "http://unix/jsonrpc", bytes.NewReader(body))
@@ -66,3 +74,24 @@ func SendRequest(socketPath string, method string, params interface{}) (*JSONRPC
 
 	return &response, nil
 }
+
+// Call sends a request and decodes its result into result, turning error responses into a *JSONRPCError.
+func Call(socketPath string, method string, params interface{}, result interface{}) error {
+	resp, err := SendRequest(socketPath, method, params)
+	if err != nil {
+		return err
+	}
+
+	return resp.Decode(result)
+}
+
+// CallTimeout is Call for methods slower than the default timeout, the timeout of their method must be set on the host
+// with Host.SetTimeout.
+func CallTimeout(socketPath string, method string, params interface{}, result interface{}, timeout time.Duration) error {
+	resp, err := sendRequest(socketPath, method, params, timeout)
+	if err != nil {
+		return err
+	}
+
+	return resp.Decode(result)
+}
@@ -1,6 +1,7 @@
 package jsonrpc
 
 import (
+	"crypto/rand"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -19,9 +20,15 @@ type Host struct {
 	mu                   sync.Mutex
 	requestsHandler      map[string]RequestHandlerFunc
 	notificationsHandler map[string]NotificationHandlerFunc
+	localHandler         map[string]RequestHandlerFunc
 	clientChannels       map[string]chan JSONRPCResponse
+	subscribers          map[string]map[chan json.RawMessage]struct{}
+	timeouts             map[string]time.Duration
 }
 
+// DefaultRequestTimeout is the time the extension has to answer a request, unless set for its method by SetTimeout
+const DefaultRequestTimeout = 5 * time.Second
+
 func (h *Host) HandleRequest(method string, handler RequestHandlerFunc) {
 	h.requestsHandler[method] = handler
 }
@@ -30,12 +37,64 @@ func (h *Host) HandleNotification(method string, handler NotificationHandlerFunc
 	h.notificationsHandler[method] = handler
 }
 
+// HandleLocalRequest registers a handler for requests sent to the host by the cli, instead of being forwarded to the extension.
+func (h *Host) HandleLocalRequest(method string, handler RequestHandlerFunc) {
+	h.localHandler[method] = handler
+}
+
+// SetTimeout sets the time the extension has to answer the requests of a method, for methods slower than DefaultRequestTimeout.
+func (h *Host) SetTimeout(method string, timeout time.Duration) {
+	h.timeouts[method] = timeout
+}
+
+func (h *Host) LocalRequestHandler(method string) (RequestHandlerFunc, bool) {
+	handler, ok := h.localHandler[method]
+	return handler, ok
+}
+
+// Subscribe returns a channel receiving the params of the notifications sent by the extension for method.
+// Notifications are dropped if the channel is full, call unsubscribe once done to release it.
+func (h *Host) Subscribe(method string) (ch <-chan json.RawMessage, unsubscribe func()) {
+	c := make(chan json.RawMessage, 16)
+
+	h.mu.Lock()
+	if h.subscribers[method] == nil {
+		h.subscribers[method] = make(map[chan json.RawMessage]struct{})
+	}
+	h.subscribers[method][c] = struct{}{}
+	h.mu.Unlock()
+
+	return c, func() {
+		h.mu.Lock()
+		delete(h.subscribers[method], c)
+		h.mu.Unlock()
+	}
+}
+
+func (h *Host) publish(method string, params json.RawMessage) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.subscribers[method] {
+		select {
+		case c <- params:
+		default:
+			h.logger.Error("subscriber is too slow, dropping notification", "method", method)
+		}
+	}
+
+	return len(h.subscribers[method]) > 0
+}
+
 func NewHost(logger *slog.Logger) *Host {
 	return &Host{
 		logger:               logger,
 		requestsHandler:      make(map[string]RequestHandlerFunc),
 		notificationsHandler: make(map[string]NotificationHandlerFunc),
+		localHandler:         make(map[string]RequestHandlerFunc),
 		clientChannels:       make(map[string]chan JSONRPCResponse),
+		subscribers:          make(map[string]map[chan json.RawMessage]struct{}),
+		timeouts:             make(map[string]time.Duration),
 	}
 }
 
@@ -102,17 +161,20 @@ func (h *Host) Listen() error {
 		}
 
 		if request.ID == "" {
+			subscribed := h.publish(request.Method, request.Params)
+
 			handler, ok := h.notificationsHandler[request.Method]
 			if !ok {
-				h.logger.Error("no handler found for notification", "method", request.Method)
+				if !subscribed {
+					h.logger.Debug("no handler found for notification", "method", request.Method)
+				}
 				continue
 			}
 
-			go func() {
-				if err := handler(request.Params); err != nil {
-					h.logger.Error("failed to handle notification", "method", request.Method, "error", err)
-				}
-			}()
+			// notifications are handled in order, so handlers must not block
+			if err := handler(request.Params); err != nil {
+				h.logger.Error("failed to handle notification", "method", request.Method, "error", err)
+			}
 
 			continue
 		}
@@ -182,7 +244,8 @@ func (h *Host) Listen() error {
 
 func (h *Host) SendRequest(request JSONRPCRequest) (JSONRPCResponse, error) {
 	h.mu.Lock()
-	responseChan := make(chan JSONRPCResponse)
+	// buffered, so that a late response does not block the listen loop
+	responseChan := make(chan JSONRPCResponse, 1)
 	h.clientChannels[request.ID] = responseChan
 	h.mu.Unlock()
 
@@ -190,14 +253,42 @@ func (h *Host) SendRequest(request JSONRPCRequest) (JSONRPCResponse, error) {
 		return JSONRPCResponse{}, fmt.Errorf("failed to write request: %w", err)
 	}
 
+	timeout, ok := h.timeouts[request.Method]
+	if !ok {
+		timeout = DefaultRequestTimeout
+	}
+
 	select {
 	case response := <-responseChan:
 		return response, nil
-	case <-time.After(5 * time.Second):
+	case <-time.After(timeout):
+		h.mu.Lock()
+		delete(h.clientChannels, request.ID)
+		h.mu.Unlock()
 		return JSONRPCResponse{}, fmt.Errorf("timeout waiting for response")
 	}
 }
 
+// Call sends a request to the extension and decodes its result into result.
+func (h *Host) Call(method string, params interface{}, result interface{}) error {
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal params: %w", err)
+	}
+
+	resp, err := h.SendRequest(JSONRPCRequest{
+		JSONRPCVersion: "2.0",
+		ID:             rand.Text(),
+		Method:         method,
+		Params:         paramsBytes,
+	})
+	if err != nil {
+		return err
+	}
+
+	return resp.Decode(result)
+}
+
 func (h *Host) SendNotification(method string, params interface{}) error {
 	paramsBytes, err := json.Marshal(params)
 	if err != nil {
@@ -217,6 +308,9 @@ func (h *Host) SendNotification(method string, params interface{}) error {
 	return nil
 }
 
+// writeMu serializes writes to stdout, as responses are sent from concurrent goroutines
+var writeMu sync.Mutex
+
 func writeMessage(data interface{}) error {
 	msg, err := json.Marshal(data)
 	if err != nil {
@@ -224,6 +318,9 @@ func writeMessage(data interface{}) error {
 	}
 	length := uint32(len(msg))
 
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
 	// Write the 4-byte length header
 	if err := binary.Write(os.Stdout, binary.LittleEndian, length); err != nil {
 		return err
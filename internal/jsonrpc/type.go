@@ -1,6 +1,9 @@
 package jsonrpc
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 type JSONRPCRequest struct {
 	JSONRPCVersion string          `json:"jsonrpc"`
@@ -15,3 +18,35 @@ type JSONRPCResponse struct {
 	Result  json.RawMessage `json:"result,omitempty"`
 	Error   json.RawMessage `json:"error,omitempty"`
 }
+
+type JSONRPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return e.Message
+}
+
+// Decode unmarshals the result of the response into result, or returns the response error as a *JSONRPCError.
+func (r JSONRPCResponse) Decode(result interface{}) error {
+	if r.Error != nil {
+		var rpcErr JSONRPCError
+		if err := json.Unmarshal(r.Error, &rpcErr); err != nil {
+			return fmt.Errorf("failed to unmarshal error: %s", r.Error)
+		}
+
+		return &rpcErr
+	}
+
+	if result == nil || len(r.Result) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(r.Result, result); err != nil {
+		return fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	return nil
+}
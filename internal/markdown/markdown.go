@@ -0,0 +1,398 @@
+// Package markdown converts HTML documents to markdown.
+package markdown
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Convert renders the HTML read from r as markdown, resolving relative links against baseURL.
+func Convert(r io.Reader, baseURL string) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	return ConvertNode(doc, baseURL), nil
+}
+
+// ConvertNode renders an already parsed HTML node as markdown.
+func ConvertNode(node *html.Node, baseURL string) string {
+	base, _ := url.Parse(baseURL)
+	c := &converter{base: base}
+	c.block(node)
+
+	return strings.TrimSpace(collapseBlankLines(c.sb.String())) + "\n"
+}
+
+var blankLinesRegexp = regexp.MustCompile(`\n{3,}`)
+
+// whitespaceLinesRegexp matches the lines left by the whitespace between blocks
+var whitespaceLinesRegexp = regexp.MustCompile(`(?m)^[ \t]+$`)
+
+func collapseBlankLines(s string) string {
+	return blankLinesRegexp.ReplaceAllString(whitespaceLinesRegexp.ReplaceAllString(s, ""), "\n\n")
+}
+
+var whitespaceRegexp = regexp.MustCompile(`\s+`)
+
+// skipped elements never contribute to the output
+var skipped = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Iframe:   true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Input:    true,
+	atom.Textarea: true,
+}
+
+type converter struct {
+	sb   strings.Builder
+	base *url.URL
+	// listDepth tracks the nesting of ul/ol elements, to indent list items
+	listDepth int
+}
+
+func (c *converter) block(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.node(child)
+	}
+}
+
+func (c *converter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		c.sb.WriteString(escapeText(whitespaceRegexp.ReplaceAllString(n.Data, " ")))
+		return
+	case html.DocumentNode:
+		c.block(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if skipped[n.DataAtom] || hasAttr(n, "hidden") || Attr(n, "aria-hidden") == "true" {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		text := strings.TrimSpace(c.inline(n))
+		if text == "" {
+			return
+		}
+		c.sb.WriteString("\n\n" + strings.Repeat("#", level) + " " + text + "\n\n")
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Footer, atom.Nav, atom.Aside, atom.Figure, atom.Form, atom.Dl:
+		c.sb.WriteString("\n\n")
+		c.block(n)
+		c.sb.WriteString("\n\n")
+	case atom.Dt:
+		c.sb.WriteString("\n\n**" + strings.TrimSpace(c.inline(n)) + "**\n")
+	case atom.Dd:
+		c.sb.WriteString(": " + strings.TrimSpace(c.inline(n)) + "\n")
+	case atom.Figcaption:
+		c.sb.WriteString("\n\n_" + strings.TrimSpace(c.inline(n)) + "_\n\n")
+	case atom.Br:
+		c.sb.WriteString("  \n")
+	case atom.Hr:
+		c.sb.WriteString("\n\n---\n\n")
+	case atom.Pre:
+		c.pre(n)
+	case atom.Blockquote:
+		inner := &converter{base: c.base}
+		inner.block(n)
+		text := strings.TrimSpace(collapseBlankLines(inner.sb.String()))
+		if text == "" {
+			return
+		}
+
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		c.sb.WriteString("\n\n" + strings.Join(lines, "\n") + "\n\n")
+	case atom.Ul, atom.Ol:
+		c.list(n)
+	case atom.Table:
+		c.table(n)
+	case atom.A, atom.Img, atom.Strong, atom.B, atom.Em, atom.I, atom.Code, atom.Del, atom.S:
+		c.sb.WriteString(c.inlineNode(n))
+	default:
+		c.block(n)
+	}
+}
+
+func (c *converter) list(n *html.Node) {
+	c.sb.WriteString("\n")
+	if c.listDepth == 0 {
+		c.sb.WriteString("\n")
+	}
+
+	index := 1
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", index)
+		}
+		index++
+
+		inner := &converter{base: c.base, listDepth: c.listDepth + 1}
+		inner.block(li)
+		text := strings.TrimSpace(collapseBlankLines(inner.sb.String()))
+
+		indent := strings.Repeat("    ", c.listDepth)
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			if i == 0 {
+				lines[i] = indent + marker + line
+				continue
+			}
+
+			if strings.TrimSpace(line) == "" {
+				lines[i] = ""
+				continue
+			}
+
+			// nested lists are already indented by their own converter
+			if strings.HasPrefix(line, indent+"    ") {
+				continue
+			}
+			lines[i] = indent + "    " + line
+		}
+
+		c.sb.WriteString(strings.Join(lines, "\n") + "\n")
+	}
+
+	if c.listDepth == 0 {
+		c.sb.WriteString("\n")
+	}
+}
+
+func (c *converter) pre(n *html.Node) {
+	language := ""
+	for _, node := range []*html.Node{n, n.FirstChild} {
+		if node == nil || node.Type != html.ElementNode {
+			continue
+		}
+
+		for _, class := range strings.Fields(Attr(node, "class")) {
+			if lang, ok := strings.CutPrefix(class, "language-"); ok {
+				language = lang
+			}
+		}
+	}
+
+	code := strings.TrimRight(TextContent(n), "\n")
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	c.sb.WriteString("\n\n" + fence + language + "\n" + code + "\n" + fence + "\n\n")
+}
+
+func (c *converter) table(n *html.Node) {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+
+			switch child.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(child)
+			case atom.Tr:
+				var row []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type != html.ElementNode || (cell.DataAtom != atom.Td && cell.DataAtom != atom.Th) {
+						continue
+					}
+
+					text := strings.TrimSpace(c.inline(cell))
+					row = append(row, strings.ReplaceAll(text, "|", `\|`))
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	walk(n)
+
+	if len(rows) == 0 {
+		return
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+
+	c.sb.WriteString("\n\n")
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+
+		c.sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			c.sb.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+		}
+	}
+	c.sb.WriteString("\n")
+}
+
+// inline renders the children of n on a single line.
+func (c *converter) inline(n *html.Node) string {
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(c.inlineNode(child))
+	}
+
+	return strings.TrimSpace(whitespaceRegexp.ReplaceAllString(sb.String(), " "))
+}
+
+func (c *converter) inlineNode(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escapeText(whitespaceRegexp.ReplaceAllString(n.Data, " "))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	if skipped[n.DataAtom] {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.A:
+		text := c.inline(n)
+		href := c.resolve(Attr(n, "href"))
+		if href == "" || strings.HasPrefix(href, "javascript:") {
+			return text
+		}
+
+		if text == "" {
+			return ""
+		}
+
+		return "[" + text + "](" + href + ")"
+	case atom.Img:
+		src := c.resolve(Attr(n, "src"))
+		if src == "" {
+			return ""
+		}
+
+		return "![" + Attr(n, "alt") + "](" + src + ")"
+	case atom.Strong, atom.B:
+		return wrap(c.inline(n), "**")
+	case atom.Em, atom.I:
+		return wrap(c.inline(n), "_")
+	case atom.Del, atom.S:
+		return wrap(c.inline(n), "~~")
+	case atom.Code:
+		code := TextContent(n)
+		fence := "`"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+
+		return fence + code + fence
+	case atom.Br:
+		return " "
+	default:
+		return c.inline(n)
+	}
+}
+
+func (c *converter) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || c.base == nil {
+		return ref
+	}
+
+	u, err := c.base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+
+	return u.String()
+}
+
+func wrap(text string, marker string) string {
+	if text == "" {
+		return ""
+	}
+
+	return marker + text + marker
+}
+
+var escapeReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+)
+
+func escapeText(text string) string {
+	return escapeReplacer.Replace(text)
+}
+
+// TextContent returns the text of a node and its descendants, with line breaks for br elements.
+func TextContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == atom.Br {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(TextContent(child))
+	}
+
+	return sb.String()
+}
+
+// Attr returns the value of an attribute of a node, or an empty string if it isn't set.
+func Attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,104 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name    string
+		html    string
+		baseURL string
+		want    string
+	}{
+		{
+			name: "paragraphs and headings",
+			html: "<h1>Title</h1><p>First   paragraph\nwith <strong>bold</strong> and <em>emphasis</em>.</p><h2></h2><p>Second</p>",
+			want: "# Title\n\nFirst paragraph with **bold** and _emphasis_.\n\nSecond\n",
+		},
+		{
+			name: "escaped text",
+			html: "<p>a * b _c_ [d]</p>",
+			want: "a \\* b \\_c\\_ \\[d\\]\n",
+		},
+		{
+			name: "unordered list",
+			html: "<ul><li>one</li><li>two</li></ul>",
+			want: "- one\n- two\n",
+		},
+		{
+			name: "ordered list",
+			html: "<ol><li>one</li><li>two</li><li>three</li></ol>",
+			want: "1. one\n2. two\n3. three\n",
+		},
+		{
+			name: "nested list",
+			html: "<ul><li>one<ul><li>nested</li></ul></li><li>two</li></ul>",
+			want: "- one\n    - nested\n- two\n",
+		},
+		{
+			name: "table",
+			html: "<table><thead><tr><th>Name</th><th>Value</th></tr></thead><tbody><tr><td>a|b</td><td>1</td></tr><tr><td>c</td></tr></tbody></table>",
+			want: "| Name | Value |\n| --- | --- |\n| a\\|b | 1 |\n| c |  |\n",
+		},
+		{
+			name: "code block with language",
+			html: `<pre><code class="language-go">func main() {
+	fmt.Println("hi")
+}
+</code></pre>`,
+			want: "```go\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```\n",
+		},
+		{
+			name: "code block containing a fence",
+			html: "<pre>```\nquoted\n```</pre>",
+			want: "````\n```\nquoted\n```\n````\n",
+		},
+		{
+			name: "inline code",
+			html: "<p>run <code>go test</code> or <code>a`b</code></p>",
+			want: "run `go test` or ``a`b``\n",
+		},
+		{
+			name: "blockquote",
+			html: "<blockquote><p>quoted</p><p>text</p></blockquote>",
+			want: "> quoted\n>\n> text\n",
+		},
+		{
+			name:    "relative links are resolved",
+			html:    `<p><a href="/docs">docs</a> <a href="page.html#top">page</a> <a href="https://other.example/">other</a></p>`,
+			baseURL: "https://example.com/guide/index.html",
+			want:    "[docs](https://example.com/docs) [page](https://example.com/guide/page.html#top) [other](https://other.example/)\n",
+		},
+		{
+			name: "links without target or text",
+			html: `<p><a href="javascript:void(0)">click</a> <a>anchor</a> <a href="/x"></a></p>`,
+			want: "click anchor\n",
+		},
+		{
+			name:    "images",
+			html:    `<p><img src="logo.png" alt="Logo"><img alt="no source"></p>`,
+			baseURL: "https://example.com/a/",
+			want:    "![Logo](https://example.com/a/logo.png)\n",
+		},
+		{
+			name: "skipped and hidden elements",
+			html: `<p>visible</p><script>alert(1)</script><p hidden>hidden</p><p aria-hidden="true">aria</p><button>button</button>`,
+			want: "visible\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Convert(strings.NewReader(test.html), test.baseURL)
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Convert() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
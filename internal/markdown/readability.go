@@ -0,0 +1,164 @@
+package markdown
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// boilerplate elements are removed before looking for the main content
+var boilerplate = map[atom.Atom]bool{
+	atom.Nav:    true,
+	atom.Header: true,
+	atom.Footer: true,
+	atom.Aside:  true,
+	atom.Form:   true,
+	atom.Menu:   true,
+	atom.Dialog: true,
+}
+
+// unlikelyRegexp matches the words of ids and class names hinting at boilerplate, words are delimited by spaces, - and _,
+// so that "site-header" matches but "subheader" doesn't.
+var unlikelyRegexp = regexp.MustCompile(`(?i)(?:^|[\s_-])(?:comments?|sidebar|footer|header|menu|nav|navbar|navigation|share|social|related|sponsor|advert|banner|cookies?|popup|modal|promo|subscribe)(?:$|[\s_-])`)
+
+// Readable renders the main content of the HTML read from r as markdown, leaving out navigation and other boilerplate.
+func Readable(r io.Reader, baseURL string) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	var title string
+	if titleNode := find(doc, atom.Title); titleNode != nil {
+		title = strings.TrimSpace(TextContent(titleNode))
+	}
+
+	removeBoilerplate(doc)
+	content := mainContent(doc)
+
+	markdown := ConvertNode(content, baseURL)
+	if title != "" && find(content, atom.H1) == nil {
+		markdown = "# " + escapeText(title) + "\n\n" + markdown
+	}
+
+	return markdown, nil
+}
+
+func removeBoilerplate(n *html.Node) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.ElementNode {
+			if boilerplate[child.DataAtom] || isUnlikely(child) {
+				n.RemoveChild(child)
+				child = next
+				continue
+			}
+
+			removeBoilerplate(child)
+		}
+		child = next
+	}
+}
+
+// isUnlikely reports whether the id, class or role of n hints at boilerplate.
+// Wrappers holding several paragraphs are kept, as layouts often tag them with classes like "has-sidebar".
+func isUnlikely(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Html, atom.Body, atom.Article, atom.Main:
+		return false
+	}
+
+	hints := Attr(n, "id") + " " + Attr(n, "class") + " " + Attr(n, "role")
+	if !unlikelyRegexp.MatchString(hints) {
+		return false
+	}
+
+	return countElements(n, atom.P) < 3
+}
+
+func countElements(n *html.Node, a atom.Atom) int {
+	count := 0
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == a {
+			count++
+		}
+		count += countElements(child, a)
+	}
+
+	return count
+}
+
+// mainContent picks the element holding the article: an explicit article or main element if there is one,
+// otherwise the element with the most paragraph text.
+func mainContent(doc *html.Node) *html.Node {
+	if article := find(doc, atom.Article); article != nil {
+		return article
+	}
+
+	if main := find(doc, atom.Main); main != nil {
+		return main
+	}
+
+	scores := make(map[*html.Node]int)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.DataAtom == atom.P || n.DataAtom == atom.Pre || n.DataAtom == atom.Blockquote) {
+			score := len(strings.TrimSpace(TextContent(n)))
+			// the parent gets the full score, the grandparent half of it
+			if parent := n.Parent; parent != nil {
+				scores[parent] += score
+				if grandparent := parent.Parent; grandparent != nil {
+					scores[grandparent] += score / 2
+				}
+			}
+			return
+		}
+
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	// ties are broken by document order, for the output not to depend on the iteration order of the map
+	var best *html.Node
+	var pick func(*html.Node)
+	pick = func(n *html.Node) {
+		if score, ok := scores[n]; ok && (best == nil || score > scores[best]) {
+			best = n
+		}
+
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			pick(child)
+		}
+	}
+	pick(doc)
+
+	if best != nil {
+		return best
+	}
+
+	if body := find(doc, atom.Body); body != nil {
+		return body
+	}
+
+	return doc
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if res := find(child, a); res != nil {
+			return res
+		}
+	}
+
+	return nil
+}
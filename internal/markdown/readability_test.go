@@ -0,0 +1,99 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadable(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "article element",
+			html: `<html><head><title>Page</title></head><body>
+				<nav><a href="/">Home</a></nav>
+				<article><h1>Article</h1><p>Body of the article.</p></article>
+				<footer>Copyright</footer>
+			</body></html>`,
+			want: "# Article\n\nBody of the article.\n",
+		},
+		{
+			name: "title is added without h1",
+			html: `<html><head><title>The Title</title></head><body><main><p>Content.</p></main></body></html>`,
+			want: "# The Title\n\nContent.\n",
+		},
+		{
+			name: "element with the most paragraph text",
+			html: `<html><body>
+				<div id="short"><p>Short.</p></div>
+				<div id="long"><p>This paragraph is much longer than the other one.</p><p>And it has a second paragraph.</p></div>
+			</body></html>`,
+			want: "This paragraph is much longer than the other one.\n\nAnd it has a second paragraph.\n",
+		},
+		{
+			name: "ties are broken by document order",
+			html: `<html><body>
+				<div><div><p>First block.</p></div></div>
+				<div><div><p>Other block.</p></div></div>
+			</body></html>`,
+			want: "First block.\n",
+		},
+		{
+			name: "boilerplate classes are removed",
+			html: `<html><body><main>
+				<div class="site-header">Site header</div>
+				<div class="subheader">Subheader</div>
+				<div id="unavailable-notice">Unavailable</div>
+				<div class="share_buttons">Share</div>
+				<p>Content.</p>
+			</main></body></html>`,
+			want: "Subheader\n\nUnavailable\n\nContent.\n",
+		},
+		{
+			name: "wrappers with several paragraphs are kept",
+			html: `<html><body><main><div class="has-sidebar"><p>One.</p><p>Two.</p><p>Three.</p></div></main></body></html>`,
+			want: "One.\n\nTwo.\n\nThree.\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for range 10 {
+				got, err := Readable(strings.NewReader(test.html), "")
+				if err != nil {
+					t.Fatalf("Readable() error = %v", err)
+				}
+
+				if got != test.want {
+					t.Fatalf("Readable() = %q, want %q", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsUnlikely(t *testing.T) {
+	tests := []struct {
+		hints string
+		want  bool
+	}{
+		{"nav", true},
+		{"main-nav", true},
+		{"site_footer", true},
+		{"comments", true},
+		{"Sidebar left", true},
+		{"unavailable", false},
+		{"subheader", false},
+		{"canvas", false},
+		{"content", false},
+	}
+
+	for _, test := range tests {
+		if got := unlikelyRegexp.MatchString(test.hints); got != test.want {
+			t.Errorf("unlikelyRegexp.MatchString(%q) = %v, want %v", test.hints, got, test.want)
+		}
+	}
+}
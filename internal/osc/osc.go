@@ -0,0 +1,101 @@
+// Package osc extracts operating system command sequences (ESC ] ... BEL) from terminal output.
+package osc
+
+import "strings"
+
+// maxSequenceSize bounds the memory used by an unterminated sequence, clipboard payloads are the largest ones
+const maxSequenceSize = 1 << 20
+
+// Sequence is an operating system command, for example ESC ] 52 ; c ; aGVsbG8= BEL
+// has the command "52" and the data "c;aGVsbG8=".
+type Sequence struct {
+	Command string
+	Data    string
+}
+
+type state int
+
+const (
+	stateGround state = iota
+	stateEscape
+	stateOSC
+	stateOSCEscape
+)
+
+// Parser is fed the output of a terminal, in chunks that may split sequences.
+// The output itself is left untouched.
+type Parser struct {
+	state state
+	buf   []byte
+	// overflow is set when the current sequence exceeds maxSequenceSize, it is dropped once terminated
+	overflow bool
+}
+
+// Feed processes a chunk of output, and returns the sequences terminated in it.
+func (p *Parser) Feed(data []byte) []Sequence {
+	var sequences []Sequence
+	for _, b := range data {
+		switch p.state {
+		case stateGround:
+			if b == 0x1b {
+				p.state = stateEscape
+			}
+		case stateEscape:
+			if b == ']' {
+				p.state = stateOSC
+				p.buf = p.buf[:0]
+				p.overflow = false
+			} else if b != 0x1b {
+				p.state = stateGround
+			}
+		case stateOSC:
+			switch b {
+			case 0x07:
+				sequences = p.terminate(sequences)
+			case 0x1b:
+				p.state = stateOSCEscape
+			case 0x18, 0x1a:
+				// CAN and SUB cancel the sequence
+				p.state = stateGround
+			default:
+				if len(p.buf) >= maxSequenceSize {
+					p.overflow = true
+					continue
+				}
+				p.buf = append(p.buf, b)
+			}
+		case stateOSCEscape:
+			if b == '\\' {
+				sequences = p.terminate(sequences)
+				continue
+			}
+
+			// any other escape aborts the sequence
+			switch b {
+			case ']':
+				p.state = stateOSC
+				p.buf = p.buf[:0]
+				p.overflow = false
+			case 0x1b:
+				p.state = stateEscape
+			default:
+				p.state = stateGround
+			}
+		}
+	}
+
+	return sequences
+}
+
+func (p *Parser) terminate(sequences []Sequence) []Sequence {
+	p.state = stateGround
+	if p.overflow {
+		return sequences
+	}
+
+	command, data, _ := strings.Cut(string(p.buf), ";")
+	return append(sequences, Sequence{
+		Command: command,
+		Data:    data,
+	})
+}
@@ -0,0 +1,123 @@
+package osc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFeed(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []Sequence
+	}{
+		{
+			name:   "BEL terminator",
+			chunks: []string{"before\x1b]2;title\x07after"},
+			want:   []Sequence{{Command: "2", Data: "title"}},
+		},
+		{
+			name:   "ST terminator",
+			chunks: []string{"\x1b]7;file://host/tmp\x1b\\"},
+			want:   []Sequence{{Command: "7", Data: "file://host/tmp"}},
+		},
+		{
+			name:   "command without data",
+			chunks: []string{"\x1b]133\x07"},
+			want:   []Sequence{{Command: "133"}},
+		},
+		{
+			name:   "data containing separators",
+			chunks: []string{"\x1b]52;c;aGVsbG8=\x07"},
+			want:   []Sequence{{Command: "52", Data: "c;aGVsbG8="}},
+		},
+		{
+			name:   "several sequences in a chunk",
+			chunks: []string{"\x1b]133;A\x07$ \x1b]133;B\x07"},
+			want:   []Sequence{{Command: "133", Data: "A"}, {Command: "133", Data: "B"}},
+		},
+		{
+			name:   "split in the data",
+			chunks: []string{"\x1b]2;ti", "tle\x07"},
+			want:   []Sequence{{Command: "2", Data: "title"}},
+		},
+		{
+			name:   "split after the escape",
+			chunks: []string{"text\x1b", "]2;title\x07"},
+			want:   []Sequence{{Command: "2", Data: "title"}},
+		},
+		{
+			name:   "split in the ST terminator",
+			chunks: []string{"\x1b]2;title\x1b", "\\"},
+			want:   []Sequence{{Command: "2", Data: "title"}},
+		},
+		{
+			name:   "split byte by byte",
+			chunks: strings.Split("\x1b]133;D;0\x1b\\", ""),
+			want:   []Sequence{{Command: "133", Data: "D;0"}},
+		},
+		{
+			name:   "other escape sequences are ignored",
+			chunks: []string{"\x1b[31mred\x1b[0m\x1bPdcs\x1b\\"},
+			want:   nil,
+		},
+		{
+			name:   "unterminated sequence",
+			chunks: []string{"\x1b]2;title", "more output"},
+			want:   nil,
+		},
+		{
+			name:   "cancelled sequence",
+			chunks: []string{"\x1b]2;title\x18\x07"},
+			want:   nil,
+		},
+		{
+			name:   "escape aborting a sequence",
+			chunks: []string{"\x1b]2;title\x1b[0m\x07"},
+			want:   nil,
+		},
+		{
+			name:   "sequence restarted before its terminator",
+			chunks: []string{"\x1b]2;first\x1b]2;second\x07"},
+			want:   []Sequence{{Command: "2", Data: "second"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var parser Parser
+			var got []Sequence
+			for _, chunk := range test.chunks {
+				got = append(got, parser.Feed([]byte(chunk))...)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Feed() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFeedOversized(t *testing.T) {
+	var parser Parser
+
+	payload := strings.Repeat("a", maxSequenceSize+1)
+	if got := parser.Feed([]byte("\x1b]52;c;" + payload[:1000])); len(got) != 0 {
+		t.Fatalf("Feed() = %q, want no sequence", got)
+	}
+
+	if got := parser.Feed([]byte(payload + "\x07")); len(got) != 0 {
+		t.Fatalf("Feed() = %d sequences for an oversized sequence, want none", len(got))
+	}
+
+	if len(parser.buf) > maxSequenceSize {
+		t.Errorf("buffer grew to %d bytes, want at most %d", len(parser.buf), maxSequenceSize)
+	}
+
+	// the parser recovers once the oversized sequence is terminated
+	want := []Sequence{{Command: "2", Data: "title"}}
+	if got := parser.Feed([]byte("\x1b]2;title\x07")); !reflect.DeepEqual(got, want) {
+		t.Errorf("Feed() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,10 @@
+#!/bin/sh
+# Copies the xterm.js assets installed for the extension to internal/cmd/xterm, where they are embedded in the binary
+# and served to the viewers of shared terminals. Run it after npm ci in the extension directory, and commit the assets.
+set -eu
+
+root="$(cd "$(dirname "$0")/.." && pwd)"
+xterm="$root/extension/node_modules/@xterm/xterm"
+
+mkdir -p "$root/internal/cmd/xterm"
+cp "$xterm/lib/xterm.js" "$xterm/css/xterm.css" "$xterm/LICENSE" "$root/internal/cmd/xterm/"